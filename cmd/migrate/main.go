@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/postgres"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlite"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/config"
+)
+
+const usage = `usage: migrate <command> [arg]
+
+commands:
+  up            apply all pending migrations
+  down [n]      roll back the last n migrations (default 1)
+  to <version>  migrate up or down to the given version (0 = roll back everything)
+  status        show applied / pending migrations
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load("configs/config.yaml")
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	store, err := persistence.Open(persistence.Config{
+		Driver: cfg.DB.Driver,
+		MySQL: mysql.Config{
+			DSN:             cfg.DB.MySQL.DSN,
+			MaxOpenConns:    2,
+			MaxIdleConns:    1,
+			ConnMaxLifetime: cfg.DB.MySQL.ConnMaxLifetime,
+		},
+		Postgres: postgres.Config{
+			DSN:             cfg.DB.Postgres.DSN,
+			MaxOpenConns:    2,
+			MaxIdleConns:    1,
+			ConnMaxLifetime: cfg.DB.Postgres.ConnMaxLifetime,
+		},
+		SQLite: sqlite.Config{
+			DSN:          cfg.DB.SQLite.DSN,
+			MaxOpenConns: cfg.DB.SQLite.MaxOpenConns,
+		},
+	})
+	if err != nil {
+		fail(err)
+	}
+	defer store.Close()
+
+	m, err := store.Migrator()
+	if err != nil {
+		fail(err)
+	}
+
+	switch cmd, arg := os.Args[1], argAt(2); cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if arg != "" {
+			if steps, err = strconv.Atoi(arg); err != nil || steps < 1 {
+				fail(fmt.Errorf("invalid step count %q", arg))
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("rolled back %d migration(s)\n", n)
+	case "to":
+		v, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			fail(fmt.Errorf("invalid version %q", arg))
+		}
+		n, err := m.To(ctx, v)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("ran %d migration(s), now at version %d\n", n, v)
+	case "status":
+		sts, err := m.Status(ctx)
+		if err != nil {
+			fail(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, st := range sts {
+			status, at := "pending", ""
+			if st.Applied {
+				status, at = "applied", st.AppliedAt.Format("2006-01-02 15:04:05")
+				if st.Mismatch {
+					status = "applied (checksum mismatch)"
+				}
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, status, at)
+		}
+		_ = tw.Flush()
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func argAt(i int) string {
+	if len(os.Args) > i {
+		return os.Args[i]
+	}
+	return ""
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate:", err)
+	os.Exit(1)
+}
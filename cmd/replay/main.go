@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	auditapp "github.com/hacker4257/go-ddd-template/internal/app/audit"
+	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
+	"github.com/hacker4257/go-ddd-template/internal/domain/audit"
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/postgres"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlite"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/config"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+)
+
+const usage = `usage: replay <command> [flags]
+
+every replayed event is upcast to the latest schema version first
+
+commands:
+  dlq [-idle 10s] [-max n]                 republish DLQ messages to their original topic
+  audit [-type T] [-after id] [-limit n]   print audit logs as NDJSON
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load("configs/config.yaml")
+	if err != nil {
+		panic(err)
+	}
+	reg, err := eventschema.NewRegistry(userapp.EventSchemas()...)
+	if err != nil {
+		fail(err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "dlq":
+		replayDLQ(ctx, &cfg, reg, args)
+	case "audit":
+		replayAudit(ctx, &cfg, reg, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// replayDLQ 用独立的 consumer group 读 DLQ，逐条升级后投回原 topic 再 commit；
+// 事件 id 不变，已经处理过的仍会被 inbox 去重。升级失败的消息跳过并打印位置，需要人工处理
+func replayDLQ(ctx context.Context, cfg *config.Config, reg *eventschema.Registry, args []string) {
+	fs := flag.NewFlagSet("dlq", flag.ExitOnError)
+	idle := fs.Duration("idle", 10*time.Second, "stop when no message arrives for this long")
+	limit := fs.Int("max", 0, "replay at most n messages (0 = all)")
+	_ = fs.Parse(args)
+
+	mode, err := cloudevents.ParseMode(cfg.Kafka.EventMode)
+	if err != nil {
+		fail(err)
+	}
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Kafka.Brokers...),
+		kgo.ConsumerGroup(cfg.Kafka.ConsumerGroup+"-dlq-replay"),
+		kgo.ConsumeTopics(cfg.Kafka.UserDLQTopic),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		fail(err)
+	}
+	defer cl.Close()
+	pub, err := kafka.NewProducer(cfg.Kafka.Brokers)
+	if err != nil {
+		fail(err)
+	}
+	defer pub.Close()
+
+	var replayed, skipped int
+	for *limit == 0 || replayed+skipped < *limit {
+		pctx, pcancel := context.WithTimeout(ctx, *idle)
+		fetches := cl.PollFetches(pctx)
+		pcancel()
+		if ctx.Err() != nil {
+			break
+		}
+		if fetches.NumRecords() == 0 {
+			if pctx.Err() != nil {
+				break // 空闲超时：DLQ 已经读完
+			}
+			for _, e := range fetches.Errors() {
+				fail(fmt.Errorf("fetch %s: %w", e.Topic, e.Err))
+			}
+			continue
+		}
+
+		for _, r := range fetches.Records() {
+			if *limit > 0 && replayed+skipped >= *limit {
+				break
+			}
+			rec, err := dlqRecord(r, reg, mode, cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "skip %s/%d@%d: %v\n", r.Topic, r.Partition, r.Offset, err)
+				skipped++
+			} else {
+				// 发布失败不 commit，下次从这条继续
+				if err := pub.PublishRaw(ctx, rec); err != nil {
+					fail(err)
+				}
+				replayed++
+			}
+			if err := cl.CommitRecords(ctx, r); err != nil {
+				fail(err)
+			}
+		}
+	}
+	fmt.Printf("replayed %d message(s), skipped %d\n", replayed, skipped)
+}
+
+// dlqRecord：升级到最新版本后重新编码，去掉 DLQ 附加的 header，目标是进入 DLQ 前的 topic
+func dlqRecord(r *kgo.Record, reg *eventschema.Registry, mode cloudevents.Mode, cfg *config.Config) (*kgo.Record, error) {
+	// 引入 CloudEvents 之前 user topic 上只有 UserCreated，旧消息进 DLQ 后按 DLQ topic 认类型
+	e, err := cloudevents.Decode(r, cloudevents.LegacyTypes{
+		cfg.Kafka.UserTopic:    user.EventCreated,
+		cfg.Kafka.UserDLQTopic: user.EventCreated,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reg.Known(e.Type) {
+		d, data, err := reg.Upcast(e.Type, e.DataSchema, e.Data)
+		if err != nil {
+			return nil, err
+		}
+		e.DataSchema, e.Data = d.URI(), data
+	}
+
+	// 引入 CloudEvents 之前的旧消息没有 source / id
+	if e.Source == "" {
+		e.Source = cfg.Kafka.EventSource
+	}
+	if e.ID == "" {
+		e.ID = fmt.Sprintf("%s:%d:%d", r.Topic, r.Partition, r.Offset)
+	}
+	topic := e.Headers["dlq_topic"]
+	if topic == "" {
+		topic = cfg.Kafka.UserTopic
+	}
+	delete(e.Headers, "dlq_topic")
+	delete(e.Headers, "dlq_reason")
+	delete(e.Headers, "retry")
+
+	value, hs, err := cloudevents.Encode(e, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &kgo.Record{Topic: topic, Key: r.Key, Value: value, Headers: hs}, nil
+}
+
+type auditLine struct {
+	ID         uint64          `json:"id"`
+	Type       string          `json:"type"`
+	Key        string          `json:"key"`
+	DataSchema string          `json:"dataschema,omitempty"`
+	Data       json.RawMessage `json:"data"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// replayAudit 把审计记录升级到最新版本后按 NDJSON 输出，可以管道给重建读模型之类的脚本
+func replayAudit(ctx context.Context, cfg *config.Config, reg *eventschema.Registry, args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	typ := fs.String("type", "", "only this event type")
+	after := fs.Uint64("after", 0, "start after this audit log id")
+	limit := fs.Int("limit", 0, "replay at most n logs (0 = all)")
+	_ = fs.Parse(args)
+
+	store, err := persistence.Open(persistence.Config{
+		Driver: cfg.DB.Driver,
+		MySQL: mysql.Config{
+			DSN:             cfg.DB.MySQL.DSN,
+			MaxOpenConns:    2,
+			MaxIdleConns:    1,
+			ConnMaxLifetime: cfg.DB.MySQL.ConnMaxLifetime,
+		},
+		Postgres: postgres.Config{
+			DSN:             cfg.DB.Postgres.DSN,
+			MaxOpenConns:    2,
+			MaxIdleConns:    1,
+			ConnMaxLifetime: cfg.DB.Postgres.ConnMaxLifetime,
+		},
+		SQLite: sqlite.Config{
+			DSN:          cfg.DB.SQLite.DSN,
+			MaxOpenConns: cfg.DB.SQLite.MaxOpenConns,
+		},
+	})
+	if err != nil {
+		fail(err)
+	}
+	defer store.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+
+	svc := auditapp.New(store.Audit, store.Transactor, store.Inbox, reg)
+	err = svc.Replay(ctx, *after, *typ, *limit, func(l audit.Log) error {
+		return enc.Encode(auditLine{
+			ID: l.ID, Type: l.EventType, Key: l.EventKey,
+			DataSchema: l.DataSchema, Data: l.Payload, CreatedAt: l.CreatedAt,
+		})
+	})
+	if err != nil {
+		w.Flush()
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "replay:", err)
+	os.Exit(1)
+}
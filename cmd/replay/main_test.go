@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/config"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+)
+
+func TestDLQRecord(t *testing.T) {
+	reg, err := eventschema.NewRegistry(userapp.EventSchemas()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg config.Config
+	cfg.Kafka.UserTopic = "user.events"
+	cfg.Kafka.UserDLQTopic = "user.events.dlq"
+	cfg.Kafka.EventSource = "/go-ddd"
+
+	// 引入 CloudEvents 之前 outbox 投递、处理失败进了 DLQ 的旧消息：value 是 payload 原文，
+	// header 只有 request_id 加上 DLQ 附加的
+	dlq := &kgo.Record{
+		Topic: "user.events.dlq", Partition: 2, Offset: 9,
+		Key:   []byte("5"),
+		Value: []byte(`{"id":5,"name":"a","email":"a@x"}`),
+		Headers: []kgo.RecordHeader{
+			{Key: "request_id", Value: []byte("req-1")},
+			{Key: "dlq_reason", Value: []byte("schema_error")},
+			{Key: "retry", Value: []byte("3")},
+			{Key: "dlq_topic", Value: []byte("user.events.v1")},
+		},
+	}
+
+	for _, mode := range []cloudevents.Mode{cloudevents.Binary, cloudevents.Structured} {
+		rec, err := dlqRecord(dlq, reg, mode, &cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Topic != "user.events.v1" || string(rec.Key) != "5" {
+			t.Errorf("mode %v: topic %q key %q", mode, rec.Topic, rec.Key)
+		}
+
+		e, err := cloudevents.Decode(rec, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.ID != "user.events.dlq:2:9" || e.Source != "/go-ddd" || e.Type != "UserCreated" {
+			t.Errorf("mode %v: attributes %+v", mode, e)
+		}
+		if want := eventschema.URI("UserCreated", 2); e.DataSchema != want {
+			t.Errorf("mode %v: dataschema %q, want %q", mode, e.DataSchema, want)
+		}
+		var p map[string]any
+		if err := json.Unmarshal(e.Data, &p); err != nil {
+			t.Fatal(err)
+		}
+		if p["version"] != float64(1) {
+			t.Errorf("mode %v: payload %s not upcast", mode, e.Data)
+		}
+		for _, k := range []string{"dlq_reason", "dlq_topic", "retry"} {
+			if _, ok := e.Headers[k]; ok {
+				t.Errorf("mode %v: header %s not stripped", mode, k)
+			}
+		}
+		if e.Headers["request_id"] != "req-1" {
+			t.Errorf("mode %v: headers %v, want request_id kept", mode, e.Headers)
+		}
+	}
+
+	// 旧版 consumer 进 DLQ 时不带 dlq_topic：回到默认 topic；payload 不合法的不投递
+	dlq.Headers = dlq.Headers[:3]
+	rec, err := dlqRecord(dlq, reg, cloudevents.Binary, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Topic != "user.events" {
+		t.Errorf("topic = %q, want user.events", rec.Topic)
+	}
+	dlq.Value = []byte(`{"id":5}`)
+	if _, err := dlqRecord(dlq, reg, cloudevents.Binary, &cfg); err == nil {
+		t.Error("invalid payload replayed, want error")
+	}
+}
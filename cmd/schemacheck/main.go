@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+)
+
+const usage = `usage: schemacheck [-base dir]
+
+checks every registered event schema:
+  - the payload Go struct matches its JSON Schema
+  - with -base: schemas already released in dir (<Type>.v<N>.json, e.g. exported from main)
+    still exist and only changed compatibly; incompatible changes need a new version
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	base := flag.String("base", "", "directory with the released schemas to compare against")
+	flag.Parse()
+
+	reg, err := eventschema.NewRegistry(userapp.EventSchemas()...)
+	if err != nil {
+		fail(err)
+	}
+
+	var problems []string
+	n := 0
+	for _, t := range reg.Types() {
+		for _, d := range reg.Versions(t) {
+			n++
+			drift, err := eventschema.CheckStruct(d)
+			if err != nil {
+				fail(err)
+			}
+			for _, p := range drift {
+				problems = append(problems, fmt.Sprintf("%s: struct drift: %s", d.URI(), p))
+			}
+		}
+	}
+
+	if *base != "" {
+		ps, err := compareBase(reg, *base)
+		if err != nil {
+			fail(err)
+		}
+		problems = append(problems, ps...)
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fail(fmt.Errorf("%d problem(s)", len(problems)))
+	}
+	fmt.Printf("ok: %d schema(s)\n", n)
+}
+
+// compareBase：base 里的每个版本都必须还在，且只做了兼容修改
+func compareBase(reg *eventschema.Registry, dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.v*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			fmt.Println("no baseline at", dir)
+			return nil, nil
+		}
+	}
+
+	var problems []string
+	for _, f := range files {
+		typ, version, ok := parseFileName(filepath.Base(f))
+		if !ok {
+			continue
+		}
+		d, err := reg.Lookup(typ, version)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: released schema was removed", eventschema.URI(typ, version)))
+			continue
+		}
+		old, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		changes, err := eventschema.Compare(old, d.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		for _, c := range changes {
+			problems = append(problems, fmt.Sprintf("%s: breaking change: %s", d.URI(), c))
+		}
+	}
+	return problems, nil
+}
+
+// parseFileName：UserCreated.v1.json → UserCreated, 1
+func parseFileName(name string) (string, int, bool) {
+	name, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndex(name, ".v")
+	if i <= 0 {
+		return "", 0, false
+	}
+	v, err := strconv.Atoi(name[i+2:])
+	if err != nil || v < 1 {
+		return "", 0, false
+	}
+	return name[:i], v, true
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "schemacheck:", err)
+	os.Exit(1)
+}
@@ -13,11 +13,20 @@ import (
 	httpapi "github.com/hacker4257/go-ddd-template/internal/api/http"
 	"github.com/hacker4257/go-ddd-template/internal/api/http/handler"
 	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
 	"github.com/hacker4257/go-ddd-template/internal/infra/cache/redis"
+	"github.com/hacker4257/go-ddd-template/internal/infra/cache/tiered"
 	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence"
 	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/postgres"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlite"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/config"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/health"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/idgen"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/logger"
 )
 
@@ -31,25 +40,61 @@ func main() {
 		slog.String("app", cfg.App.Name),
 		slog.String("env", cfg.App.Env),
 	)
-	//mysql
-	db, err := mysql.Open(mysql.Config{
-		DSN:             cfg.DB.MySQL.DSN,
-		MaxOpenConns:    cfg.DB.MySQL.MaxOpenConns,
-		MaxIdleConns:    cfg.DB.MySQL.MaxIdleConns,
-		ConnMaxLifetime: cfg.DB.MySQL.ConnMaxLifetime,
+	//db
+	store, err := persistence.Open(persistence.Config{
+		Driver: cfg.DB.Driver,
+		MySQL: mysql.Config{
+			DSN:             cfg.DB.MySQL.DSN,
+			MaxOpenConns:    cfg.DB.MySQL.MaxOpenConns,
+			MaxIdleConns:    cfg.DB.MySQL.MaxIdleConns,
+			ConnMaxLifetime: cfg.DB.MySQL.ConnMaxLifetime,
+			SlowQuery:       cfg.DB.MySQL.SlowQuery,
+			Logger:          log,
+		},
+		MySQLReplicas: mysql.ReplicaConfig{
+			DSNs:          cfg.DB.MySQL.Replicas,
+			MaxLag:        cfg.DB.MySQL.ReplicaMaxLag,
+			CheckInterval: cfg.DB.MySQL.ReplicaCheckInterval,
+		},
+		Postgres: postgres.Config{
+			DSN:             cfg.DB.Postgres.DSN,
+			MaxOpenConns:    cfg.DB.Postgres.MaxOpenConns,
+			MaxIdleConns:    cfg.DB.Postgres.MaxIdleConns,
+			ConnMaxLifetime: cfg.DB.Postgres.ConnMaxLifetime,
+		},
+		SQLite: sqlite.Config{
+			DSN:          cfg.DB.SQLite.DSN,
+			MaxOpenConns: cfg.DB.SQLite.MaxOpenConns,
+		},
+		TxRetry: sqltx.RetryConfig{
+			MaxRetries: cfg.DB.TxMaxRetries,
+			BaseDelay:  cfg.DB.TxRetryBaseDelay,
+			MaxDelay:   cfg.DB.TxRetryMaxDelay,
+		},
+		StatsInterval: cfg.DB.MySQL.StatsInterval,
 	})
 	if err != nil {
 		log.Error("db_open_error", slog.Any("err", err))
 		os.Exit(1)
 	}
-	defer db.Close()
+	defer store.Close()
+
+	if cfg.DB.AutoMigrate {
+		m, err := store.Migrator()
+		if err != nil {
+			log.Error("migrate_load_error", slog.Any("err", err))
+			os.Exit(1)
+		}
+		n, err := m.Up(context.Background())
+		if err != nil {
+			log.Error("migrate_error", slog.Any("err", err))
+			os.Exit(1)
+		}
+		log.Info("migrate_done", slog.Int("applied", n))
+	}
 
 	//redis
-	rdb, err := redis.NewClient(redis.Config{
-		Addr:     cfg.Redis.Addr,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+	rdb, err := redis.NewClient(redis.FromConfig(cfg.Redis))
 	if err != nil {
 		log.Error("redis_open_error", slog.Any("err", err))
 		os.Exit(1)
@@ -64,18 +109,64 @@ func main() {
 	}
 	defer kpub.Close()
 
-	transactor := mysql.NewTransactor(db)
-	outboxStore := mysql.NewOutboxStore(db)
+	transactor := store.Transactor
+
+	// 事件先过 schema 校验再写 outbox，不合法的事件让整个事务失败
+	events, err := eventschema.NewRegistry(userapp.EventSchemas()...)
+	if err != nil {
+		log.Error("event_schema_error", slog.Any("err", err))
+		os.Exit(1)
+	}
+	var outboxStore event.Outbox = persistence.ValidateOnAdd(store.Outbox, events)
+
+	// 后台任务（缓存失效订阅等）跟随进程生命周期
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+
+	if cfg.Redis.OutboxNudge {
+		nudge := redis.NewOutboxNudge(rdb, cfg.Redis.OutboxNudgeChannel)
+		go nudge.Run(bgCtx, log)
+		outboxStore = persistence.NotifyOnCommit(outboxStore, nudge)
+	}
+
+	userCodec, err := redis.NewUserCodec(cfg.Redis.CacheCodec, cfg.Redis.CacheCompressOver)
+	if err != nil {
+		log.Error("cache_codec_error", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	var userCache user.Cache = redis.NewUserCache(rdb, userCodec)
+	if cfg.Redis.UserLocalCache {
+		bus := redis.NewInvalidationBus(rdb, cfg.Redis.InvalidationChannel)
+		local := tiered.NewUserCache(userCache, bus, cfg.Redis.UserLocalSize, cfg.Redis.UserLocalTTL)
+		go bus.Subscribe(bgCtx, log, local.EvictLocal, local.PurgeLocal)
+		userCache = local
+	}
+	if cfg.ID.Node < 0 {
+		// 两个副本都用默认值会发出相同的 id
+		log.Error("idgen_error", slog.String("err", "id.node is not set: give every replica a unique ID_NODE"))
+		os.Exit(1)
+	}
+	ids, err := idgen.NewSnowflake(cfg.ID.Node, cfg.ID.MaxClockBackward)
+	if err != nil {
+		log.Error("idgen_error", slog.Any("err", err))
+		os.Exit(1)
+	}
 
-	userCache := redis.NewUserCache(rdb)
-	userRepo := mysql.NewUserRepo(db)
-	userSvc := userapp.New(userRepo, userCache, cfg.Redis.UserTTL, transactor, outboxStore, cfg.Kafka.UserTopic)
+	userRepo := store.Users
+	userSvc := userapp.New(userRepo, userCache, userapp.CachePolicy{
+		TTL:              cfg.Redis.UserTTL,
+		TTLJitter:        cfg.Redis.UserTTLJitter,
+		NegativeTTL:      cfg.Redis.UserNegativeTTL,
+		EarlyRefresh:     cfg.Redis.UserEarlyRefresh,
+		EarlyRefreshBeta: cfg.Redis.UserEarlyRefreshBeta,
+	}, transactor, outboxStore, cfg.Kafka.UserTopic, ids)
 
 	userHandler := handler.NewUserHandler(userSvc)
 
 	readyHandler := handler.ReadyHandler{
 	Checker: health.Checker{
-		DB:      db,
+		DB:      store.DB,
 		Redis:   rdb,
 		Brokers: cfg.Kafka.Brokers,
 		Timeout: 2 * time.Second,
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/cache/redis"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// userCachePayload：user 事件里和缓存相关的字段
+type userCachePayload struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Version   uint64    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CacheSyncConsumer：消费 user 事件，刷新/删除 Redis 缓存
+// 独立 consumer group，不管写入来自哪个进程，缓存都能最终一致
+type CacheSyncConsumer struct {
+	log    *slog.Logger
+	cl     *kgo.Client
+	cache  user.Cache
+	events *eventschema.Registry
+	legacy cloudevents.LegacyTypes
+	bus    *redis.InvalidationBus // 通知 server 副本清理进程内缓存，可为 nil
+	ttl    time.Duration
+}
+
+func NewCacheSyncConsumer(log *slog.Logger, brokers []string, group string, topic string,
+	cache user.Cache, events *eventschema.Registry, bus *redis.InvalidationBus, ttl time.Duration,
+) (*CacheSyncConsumer, error) {
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeTopics(topic),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheSyncConsumer{log: log, cl: cl, cache: cache, events: events, legacy: legacyUserTypes(topic), bus: bus, ttl: ttl}, nil
+}
+
+func (c *CacheSyncConsumer) Close() {
+	c.cl.Close()
+}
+
+func (c *CacheSyncConsumer) Run(ctx context.Context) {
+	for {
+		fetches := c.cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			for _, e := range errs {
+				c.log.Error("cache_sync_fetch_error", slog.Any("err", e.Err))
+			}
+			continue
+		}
+
+		fetches.EachRecord(func(r *kgo.Record) {
+			c.handleRecord(ctx, r)
+		})
+	}
+}
+
+func (c *CacheSyncConsumer) handleRecord(ctx context.Context, r *kgo.Record) {
+	var p userCachePayload
+	e, err := cloudevents.Decode(r, c.legacy)
+	if err == nil {
+		err = upcast(c.events, &e)
+	}
+	if err == nil {
+		err = json.Unmarshal(e.Data, &p)
+	}
+	evtType := e.Type
+	if err != nil || p.ID == 0 {
+		// 不是我们认识的 user 事件：跳过（缓存同步不做 DLQ）
+		c.log.Warn("cache_sync_skip", slog.String("type", evtType), slog.Any("err", err))
+		c.cl.CommitRecords(ctx, r)
+		return
+	}
+
+	// 失败时原地退避重试，直到成功或退出：后面的 commit 会越过这条 offset，跳过它就等于丢了这次缓存更新
+	wait := 50 * time.Millisecond
+	for {
+		err = c.apply(ctx, evtType, p)
+		if err == nil {
+			break
+		}
+		c.log.Error("cache_sync_error", slog.Uint64("id", p.ID), slog.Any("err", err))
+		metrics.CacheSyncFailedTotal.Add(1)
+		select {
+		case <-ctx.Done():
+			return // 不 commit：重启后从这条继续
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, 5*time.Second)
+	}
+
+	if c.bus != nil {
+		_ = c.bus.Publish(ctx, p.ID) // best effort：server 本地缓存 TTL 很短
+	}
+	metrics.CacheSyncAppliedTotal.Add(1)
+	c.cl.CommitRecords(ctx, r)
+}
+
+// apply 把一条事件同步到缓存；缓存操作是幂等的，可以重复执行
+func (c *CacheSyncConsumer) apply(ctx context.Context, evtType string, p userCachePayload) error {
+	switch evtType {
+	case "UserCreated", "UserUpdated":
+		err := c.cache.Set(ctx, user.User{
+			ID: p.ID, Name: p.Name, Email: p.Email, Version: p.Version, CreatedAt: p.CreatedAt,
+		}, c.ttl)
+		if errors.Is(err, user.ErrStaleVersion) {
+			// 缓存里已经是更新的版本：正常情况，不算失败
+			metrics.CacheSyncStaleTotal.Add(1)
+			return nil
+		}
+		return err
+	case "UserDeleted":
+		return c.cache.Evict(ctx, p.ID, p.Version)
+	default:
+		// 未知类型：保守起见直接删缓存，下次读回源
+		return c.cache.Del(ctx, p.ID)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// InboxJanitorConfig：Retention 之前的去重记录分批删除，BatchPause 控制删除速率
+type InboxJanitorConfig struct {
+	Retention  time.Duration
+	Interval   time.Duration
+	BatchSize  int
+	BatchPause time.Duration
+}
+
+// InboxJanitor：inbox 保留期清理；多个副本同时运行也只是重复删除，无副作用
+type InboxJanitor struct {
+	log   *slog.Logger
+	store event.InboxRetention
+	cfg   InboxJanitorConfig
+}
+
+func NewInboxJanitor(log *slog.Logger, store event.InboxRetention, cfg InboxJanitorConfig) *InboxJanitor {
+	return &InboxJanitor{log: log, store: store, cfg: cfg}
+}
+
+func (j *InboxJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *InboxJanitor) runOnce(ctx context.Context) {
+	before := time.Now().Add(-j.cfg.Retention)
+
+	var purged int64
+	for {
+		n, err := j.store.Purge(ctx, before, j.cfg.BatchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				j.log.Error("inbox_purge_error", slog.Any("err", err))
+			}
+			break
+		}
+		purged += n
+		metrics.InboxPurgedTotal.Add(n)
+		if n < int64(j.cfg.BatchSize) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.cfg.BatchPause):
+		}
+	}
+
+	if purged > 0 {
+		j.log.Info("inbox_purged", slog.Int64("rows", purged), slog.Time("before", before))
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"time"
@@ -11,29 +10,29 @@ import (
 
 	auditapp "github.com/hacker4257/go-ddd-template/internal/app/audit"
 	"github.com/hacker4257/go-ddd-template/internal/infra/idempotency"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
 	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
 )
 
-type UserEvent struct {
-	Type       string                 `json:"type"`
-	Key        string                 `json:"key"`
-	OccurredAt string                 `json:"occurred_at"`
-	Payload    map[string]any         `json:"payload"`
-}
-
 type UserConsumer struct {
 	log        *slog.Logger
 	cl         *kgo.Client
+	group      string // inbox 里的 consumer 名
 	audit      *auditapp.Service
-	idem       *idempotency.Store
+	events     *eventschema.Registry
+	legacy     cloudevents.LegacyTypes
+	idem       *idempotency.Store // 可选：Redis 处理租约 + 快速过滤，nil 时只靠 inbox
+	leaseTTL   time.Duration      // 处理中租约，持有者崩溃后到期
+	idemTTL    time.Duration      // 处理完成标记的保留时间
 	dlqTopic   string
 	maxRetries int
 	producer   *kafka.Producer
 }
 
 func NewUserConsumer(log *slog.Logger, brokers []string, group string, topic string, dlqTopic string, maxRetries int,
-	audit *auditapp.Service, idem *idempotency.Store, producer *kafka.Producer,
+	audit *auditapp.Service, events *eventschema.Registry, idem *idempotency.Store, leaseTTL, idemTTL time.Duration, producer *kafka.Producer,
 ) (*UserConsumer, error) {
 	cl, err := kgo.NewClient(
 		kgo.SeedBrokers(brokers...),
@@ -46,8 +45,8 @@ func NewUserConsumer(log *slog.Logger, brokers []string, group string, topic str
 	}
 
 	return &UserConsumer{
-		log: log, cl: cl,
-		audit: audit, idem: idem,
+		log: log, cl: cl, group: group,
+		audit: audit, events: events, legacy: legacyUserTypes(topic), idem: idem, leaseTTL: leaseTTL, idemTTL: idemTTL,
 		dlqTopic: dlqTopic, maxRetries: maxRetries,
 		producer: producer,
 	}, nil
@@ -74,60 +73,132 @@ func (c *UserConsumer) Run(ctx context.Context) {
 }
 
 func (c *UserConsumer) handleRecord(ctx context.Context, r *kgo.Record) {
-	// 幂等 key：topic-partition-offset（足够做演示；生产可用 message_id）
-	idemKey := fmt.Sprintf("%s:%d:%d", r.Topic, r.Partition, r.Offset)
-
-	first, err := c.idem.TryMarkProcessed(ctx, idemKey, 24*time.Hour)
+	e, err := cloudevents.Decode(r, c.legacy)
 	if err != nil {
-		c.log.Error("idem_error", slog.Any("err", err))
-		metrics.ConsumerFailedTotal.Add(1)
-		return // 不 commit，后续重试
+		// 格式错误重试也不会好：直接转 DLQ
+		c.log.Error("event_decode_error", slog.Any("err", err))
+		c.sendDLQ(ctx, r, "decode_error", headerInt(r.Headers, "retry"))
+		metrics.ConsumerProcessedTotal.Add(1)
+		c.cl.CommitRecords(ctx, r)
+		return
 	}
-	if !first {
-		// 重复消息：直接 commit 跳过
+	// 旧版本的 payload 先升级到最新版本；DLQ 里保留原始消息，重放时再升级
+	if err := upcast(c.events, &e); err != nil {
+		c.log.Error("event_schema_error", slog.String("type", e.Type), slog.Any("err", err))
+		c.sendDLQ(ctx, r, "schema_error", headerInt(r.Headers, "retry"))
+		metrics.ConsumerProcessedTotal.Add(1)
 		c.cl.CommitRecords(ctx, r)
-		metrics.ConsumerFailedTotal.Add(1)
 		return
 	}
+	eventID := recordEventID(r, e)
 
-	// 重试次数（从 header 读取）
-	retry := headerInt(r.Headers, "retry")
-
-	// 解析事件
-	var evt UserEvent
-	if err := json.Unmarshal(r.Value, &evt); err != nil {
-		c.log.Error("event_unmarshal_error", slog.Any("err", err))
-		c.sendDLQ(ctx, r, "unmarshal_error", retry)
-		metrics.ConsumerProcessedTotal.Add(1)
+	lease, done, err := c.acquire(ctx, c.group+":"+eventID)
+	if err != nil {
+		return // 退出中：不 commit，重启后重新消费
+	}
+	if done {
+		metrics.InboxDuplicatesTotal.Add(1)
+		metrics.InboxFilteredTotal.Add(1)
 		c.cl.CommitRecords(ctx, r)
 		return
 	}
 
+	ok := c.process(ctx, r, e, eventID)
+	if lease == nil {
+		return
+	}
+
+	// 不跟随 ctx：退出时也要把租约收尾，否则重投的消息要等租约过期
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+	if ok {
+		err = c.idem.Complete(fctx, *lease, c.idemTTL)
+	} else {
+		err = c.idem.Abandon(fctx, *lease)
+	}
+	if err != nil {
+		c.log.Warn("idem_release_error", slog.Bool("completed", ok), slog.Any("err", err))
+	}
+}
+
+// acquire 拿处理租约：done 表示已经处理过，应跳过；同一事件正在别处处理时退避等待，不能直接 ack
+// 对方崩溃时租约到期后即可拿到。Redis 不可用时不拿租约直接处理，去重由 inbox 兜底
+func (c *UserConsumer) acquire(ctx context.Context, key string) (lease *idempotency.Lease, done bool, err error) {
+	if c.idem == nil {
+		return nil, false, nil
+	}
+
+	wait := 50 * time.Millisecond
+	for {
+		st, l, err := c.idem.Begin(ctx, key, c.leaseTTL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, false, ctx.Err()
+			}
+			c.log.Warn("idem_error", slog.Any("err", err))
+			return nil, false, nil
+		}
+		switch st {
+		case idempotency.Acquired:
+			return &l, false, nil
+		case idempotency.Done:
+			return nil, true, nil
+		}
+
+		metrics.InboxLeaseWaitsTotal.Add(1)
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, time.Second)
+	}
+}
+
+// process 执行副作用并 commit offset；返回 false 表示没有处理成功（已重投 / 转 DLQ / 等待重试）
+func (c *UserConsumer) process(ctx context.Context, r *kgo.Record, e cloudevents.Event, eventID string) bool {
+	// 重试次数（从 header 读取）
+	retry := headerInt(r.Headers, "retry")
+
 	// 示例：只处理 UserCreated
-	if evt.Type == "UserCreated" {
-		payloadBytes := r.Value // 原样落库，最简单
-		if err := c.audit.Record(ctx, evt.Type, evt.Key, payloadBytes); err != nil {
+	if e.Type == "UserCreated" {
+		// inbox 和审计在同一个事务里：重复消息什么都不写，崩溃后重投也不会漏记
+		first, err := c.audit.RecordOnce(ctx, c.group, eventID, e.Type, e.Subject, e.DataSchema, e.Data)
+		if err != nil {
 			c.log.Error("audit_record_error", slog.Any("err", err))
 
 			if retry+1 >= c.maxRetries {
 				c.sendDLQ(ctx, r, "max_retries_exceeded", retry)
 				c.cl.CommitRecords(ctx, r)
-				return
+				return false
 			}
 
 			// 重新投递到原 topic（带 retry+1 header），然后 commit 当前 offset，避免堵塞
 			if err := c.requeue(ctx, r, retry+1); err != nil {
 				// requeue 失败：不 commit，让它重试
 				c.log.Error("requeue_error", slog.Any("err", err))
-				return
+				return false
 			}
 			c.cl.CommitRecords(ctx, r)
-			return
+			return false
+		}
+		if !first {
+			metrics.InboxDuplicatesTotal.Add(1)
 		}
 	}
 
 	// 成功：commit
 	c.cl.CommitRecords(ctx, r)
+	return true
+}
+
+// recordEventID：CloudEvents id 即 outbox 行 id；没有时（旧消息）退回 topic:partition:offset，
+// requeue 会带上原 header，重投的副本仍是同一个 id
+func recordEventID(r *kgo.Record, e cloudevents.Event) string {
+	if e.ID != "" {
+		return e.ID
+	}
+	return fmt.Sprintf("%s:%d:%d", r.Topic, r.Partition, r.Offset)
 }
 
 func (c *UserConsumer) requeue(ctx context.Context, r *kgo.Record, retry int) error {
@@ -149,6 +220,7 @@ func (c *UserConsumer) sendDLQ(ctx context.Context, r *kgo.Record, reason string
 		Value: r.Value,
 		Headers: append(copyHeaders(r.Headers),
 			kgo.RecordHeader{Key: "dlq_reason", Value: []byte(reason)},
+			kgo.RecordHeader{Key: "dlq_topic", Value: []byte(r.Topic)}, // 重放时投回原 topic
 			kgo.RecordHeader{Key: "retry", Value: []byte(fmt.Sprintf("%d", retry))},
 		),
 	}
@@ -8,12 +8,20 @@ import (
 	"syscall"
 
 	auditapp "github.com/hacker4257/go-ddd-template/internal/app/audit"
+	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
 	"github.com/hacker4257/go-ddd-template/internal/infra/cache/redis"
 	"github.com/hacker4257/go-ddd-template/internal/infra/idempotency"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
 	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence"
 	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/postgres"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlite"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/config"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/logger"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/wakeup"
 )
 
 func main() {
@@ -28,25 +36,47 @@ func main() {
 		slog.String("proc", "worker"),
 	)
 
-	// ---------- MySQL ----------
-	db, err := mysql.Open(mysql.Config{
-		DSN:             cfg.DB.MySQL.DSN,
-		MaxOpenConns:    cfg.DB.MySQL.MaxOpenConns,
-		MaxIdleConns:    cfg.DB.MySQL.MaxIdleConns,
-		ConnMaxLifetime: cfg.DB.MySQL.ConnMaxLifetime,
+	// ---------- DB ----------
+	store, err := persistence.Open(persistence.Config{
+		Driver: cfg.DB.Driver,
+		MySQL: mysql.Config{
+			DSN:             cfg.DB.MySQL.DSN,
+			MaxOpenConns:    cfg.DB.MySQL.MaxOpenConns,
+			MaxIdleConns:    cfg.DB.MySQL.MaxIdleConns,
+			ConnMaxLifetime: cfg.DB.MySQL.ConnMaxLifetime,
+			SlowQuery:       cfg.DB.MySQL.SlowQuery,
+			Logger:          log,
+		},
+		MySQLReplicas: mysql.ReplicaConfig{
+			DSNs:          cfg.DB.MySQL.Replicas,
+			MaxLag:        cfg.DB.MySQL.ReplicaMaxLag,
+			CheckInterval: cfg.DB.MySQL.ReplicaCheckInterval,
+		},
+		Postgres: postgres.Config{
+			DSN:             cfg.DB.Postgres.DSN,
+			MaxOpenConns:    cfg.DB.Postgres.MaxOpenConns,
+			MaxIdleConns:    cfg.DB.Postgres.MaxIdleConns,
+			ConnMaxLifetime: cfg.DB.Postgres.ConnMaxLifetime,
+		},
+		SQLite: sqlite.Config{
+			DSN:          cfg.DB.SQLite.DSN,
+			MaxOpenConns: cfg.DB.SQLite.MaxOpenConns,
+		},
+		TxRetry: sqltx.RetryConfig{
+			MaxRetries: cfg.DB.TxMaxRetries,
+			BaseDelay:  cfg.DB.TxRetryBaseDelay,
+			MaxDelay:   cfg.DB.TxRetryMaxDelay,
+		},
+		StatsInterval: cfg.DB.MySQL.StatsInterval,
 	})
 	if err != nil {
 		log.Error("db_open_error", slog.Any("err", err))
 		os.Exit(1)
 	}
-	defer db.Close()
+	defer store.Close()
 
 	// ---------- Redis ----------
-	rdb, err := redis.NewClient(redis.Config{
-		Addr:     cfg.Redis.Addr,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+	rdb, err := redis.NewClient(redis.FromConfig(cfg.Redis))
 	if err != nil {
 		log.Error("redis_open_error", slog.Any("err", err))
 		os.Exit(1)
@@ -71,16 +101,94 @@ func main() {
 	startWorkerHTTP(ctx, log, cfg.Worker.HTTP.Addr)
 
 	// ---------- Outbox Dispatcher ----------
-	outboxStore := mysql.NewOutboxStore(db)
-	dispatcher := NewOutboxDispatcher(log, outboxStore, kpub)
+	eventMode, err := cloudevents.ParseMode(cfg.Kafka.EventMode)
+	if err != nil {
+		log.Error("config_error", slog.Any("err", err))
+		os.Exit(1)
+	}
+	outboxStore := store.Outbox
+	wake := wakeup.NewSignal()
+	if cfg.Redis.OutboxNudge {
+		nudge := redis.NewOutboxNudge(rdb, cfg.Redis.OutboxNudgeChannel)
+		go nudge.Subscribe(ctx, log, func() { wake.Notify(ctx) })
+	}
+	// cdc 模式只支持 MySQL；其他 driver 继续轮询
+	var binlog *mysql.OutboxBinlog
+	if cfg.Worker.Outbox.Mode == "cdc" {
+		if store.Driver != persistence.DriverMySQL {
+			log.Warn("outbox_cdc_unsupported", slog.String("driver", store.Driver))
+		} else {
+			binlog, err = mysql.NewOutboxBinlog(store.DB, mysql.BinlogConfig{
+				DSN:      cfg.DB.MySQL.DSN,
+				ServerID: cfg.Worker.Outbox.CDCServerID,
+				Logger:   log,
+			})
+			if err != nil {
+				log.Error("outbox_cdc_error", slog.Any("err", err))
+				os.Exit(1)
+			}
+		}
+	}
+	dispatcher := NewOutboxDispatcher(log, outboxStore, kpub, DispatcherConfig{
+		Owner:          instanceID(),
+		Lease:          cfg.Worker.Outbox.Lease,
+		MaxAttempts:    cfg.Worker.Outbox.MaxAttempts,
+		RetryBaseDelay: cfg.Worker.Outbox.RetryBaseDelay,
+		RetryMaxDelay:  cfg.Worker.Outbox.RetryMaxDelay,
+
+		BatchSize:       cfg.Worker.Outbox.BatchSize,
+		PollInterval:    cfg.Worker.Outbox.PollInterval,
+		MaxPollInterval: cfg.Worker.Outbox.MaxPollInterval,
+		Lanes:           cfg.Worker.Outbox.Lanes,
+		Wakeup:          wake.C(),
+
+		Source:   cfg.Kafka.EventSource,
+		Encoding: eventMode,
+
+		Binlog:        binlog,
+		RetryInterval: cfg.Worker.Outbox.CDCRetryInterval,
+		SweepInterval: cfg.Worker.Outbox.CDCSweepInterval,
+	})
 	go dispatcher.Run(ctx)
 
-	// ---------- Idempotency Store ----------
-	idem := idempotency.New(rdb)
+	if cfg.Worker.Outbox.Retention > 0 {
+		janitor := NewOutboxJanitor(log, outboxStore, JanitorConfig{
+			Retention:  cfg.Worker.Outbox.Retention,
+			Interval:   cfg.Worker.Outbox.PurgeInterval,
+			BatchSize:  cfg.Worker.Outbox.PurgeBatchSize,
+			BatchPause: cfg.Worker.Outbox.PurgeBatchPause,
+			MaxPerRun:  cfg.Worker.Outbox.PurgeMaxPerRun,
+			ArchiveDir: cfg.Worker.Outbox.ArchiveDir,
+		})
+		go janitor.Run(ctx)
+	}
+
+	// ---------- Inbox ----------
+	// 去重以数据库 inbox 为准；Redis 只做可选的处理租约 + 快速过滤
+	var idem *idempotency.Store
+	if cfg.Worker.Inbox.RedisFilter {
+		idem = idempotency.New(rdb)
+	}
+	if cfg.Worker.Inbox.Retention > 0 {
+		inboxJanitor := NewInboxJanitor(log, store.Inbox, InboxJanitorConfig{
+			Retention:  cfg.Worker.Inbox.Retention,
+			Interval:   cfg.Worker.Inbox.PurgeInterval,
+			BatchSize:  cfg.Worker.Inbox.PurgeBatchSize,
+			BatchPause: cfg.Worker.Inbox.PurgeBatchPause,
+		})
+		go inboxJanitor.Run(ctx)
+	}
+
+	// ---------- Event Schemas ----------
+	events, err := eventschema.NewRegistry(userapp.EventSchemas()...)
+	if err != nil {
+		log.Error("event_schema_error", slog.Any("err", err))
+		os.Exit(1)
+	}
 
 	// ---------- Audit Service ----------
-	auditRepo := mysql.NewAuditRepo(db)
-	auditSvc := auditapp.New(auditRepo)
+	auditRepo := store.Audit
+	auditSvc := auditapp.New(auditRepo, store.Transactor, store.Inbox, events)
 
 	// ---------- Kafka Consumer ----------
 	consumer, err := NewUserConsumer(
@@ -91,7 +199,10 @@ func main() {
 		cfg.Kafka.UserDLQTopic,
 		cfg.Kafka.MaxRetries,
 		auditSvc,
+		events,
 		idem,
+		cfg.Worker.Inbox.LeaseTTL,
+		cfg.Worker.Inbox.RedisTTL,
 		kpub, // 用同一个 producer 做 requeue + DLQ
 	)
 	if err != nil {
@@ -102,6 +213,30 @@ func main() {
 
 	go consumer.Run(ctx)
 
+	// ---------- Cache Sync Consumer ----------
+	userCodec, err := redis.NewUserCodec(cfg.Redis.CacheCodec, cfg.Redis.CacheCompressOver)
+	if err != nil {
+		log.Error("cache_codec_error", slog.Any("err", err))
+		os.Exit(1)
+	}
+	cacheSync, err := NewCacheSyncConsumer(
+		log,
+		cfg.Kafka.Brokers,
+		cfg.Kafka.CacheConsumerGroup,
+		cfg.Kafka.UserTopic,
+		redis.NewUserCache(rdb, userCodec),
+		events,
+		redis.NewInvalidationBus(rdb, cfg.Redis.InvalidationChannel),
+		cfg.Redis.UserTTL,
+	)
+	if err != nil {
+		log.Error("cache_sync_consumer_error", slog.Any("err", err))
+		os.Exit(1)
+	}
+	defer cacheSync.Close()
+
+	go cacheSync.Run(ctx)
+
 	log.Info("worker_started")
 
 	<-stop
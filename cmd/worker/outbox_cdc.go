@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// binlog 中继（worker.outbox.mode = cdc）：
+//   - 按事务提交顺序从 binlog 拿到 outbox 新插入的 id，回表读取仍为 pending 的行后按 key 有序发布
+//   - 发布失败的行记一次失败（next_attempt_at 退避）后交给轮询重试，同 key 的后续行也留给轮询，
+//     其他 key 不受影响；同 key 还有更早的 pending 行时，回表不会读到它（见 mysql.OutboxBinlog.Load）
+//   - 一批里每一行都已发送、dead 或交给轮询之后才保存位点，重启从位点继续；崩溃只会重发最后一批（至少一次语义）
+//   - 同一时刻只有一个副本在中继（GET_LOCK），其余副本待命，既不订阅也不轮询：
+//     中继不领租约，待命副本轮询会和它并发发布同一行、打乱 key 顺序；中继副本退出后锁释放，待命副本在 RetryInterval 内接手
+//   - binlog 不可用或订阅出错时回退到轮询 RetryInterval，然后重试订阅
+const (
+	cdcLinger    = 5 * time.Millisecond // 攒批：事务之间空闲超过该值就发出
+	cdcSaveEvery = time.Second          // 没有 outbox 写入时，位点最多每秒保存一次
+)
+
+// binlogSource：*mysql.OutboxBinlog，测试里替换成假的
+type binlogSource interface {
+	Start(ctx context.Context) (mysql.BinlogPosition, error)
+	Next(ctx context.Context) ([]uint64, mysql.BinlogPosition, error)
+	Load(ctx context.Context, ids []uint64) ([]event.OutboxRecord, error)
+	Save(ctx context.Context, pos mysql.BinlogPosition) error
+	Close() error
+}
+
+func (d *OutboxDispatcher) runCDC(ctx context.Context, b binlogSource) {
+	for ctx.Err() == nil {
+		err := d.relayBinlog(ctx, b)
+		metrics.OutboxCDCActive.Set(0)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, mysql.ErrBinlogLocked) {
+			d.log.Info("outbox_cdc_standby", slog.Duration("retry_in", d.cfg.RetryInterval))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.cfg.RetryInterval):
+			}
+			continue
+		}
+
+		metrics.OutboxCDCFallbacksTotal.Add(1)
+		d.log.Warn("outbox_cdc_fallback_poll", slog.Duration("retry_in", d.cfg.RetryInterval), slog.Any("err", err))
+		pctx, cancel := context.WithTimeout(ctx, d.cfg.RetryInterval)
+		d.runPoll(pctx)
+		cancel()
+	}
+}
+
+// relayBinlog 订阅直到出错或 ctx 结束
+func (d *OutboxDispatcher) relayBinlog(ctx context.Context, b binlogSource) error {
+	pos, err := b.Start(ctx)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	metrics.OutboxCDCActive.Set(1)
+	d.log.Info("outbox_cdc_started", slog.String("file", pos.File), slog.Uint64("pos", uint64(pos.Pos)))
+
+	var (
+		ids       []uint64
+		first     time.Time // 攒批中最早一个事务的读取时间
+		last      = pos
+		saved     = pos
+		savedAt   = time.Now()
+		nextSweep time.Time // 零值：启动时先补发一次
+	)
+	for {
+		if !time.Now().Before(nextSweep) {
+			// 位点之前插入、中继发布失败交过来的、或切换模式前遗留的行；和中继在同一个协程里，不会并发重复发布
+			for d.drainOnce(ctx) >= d.cfg.BatchSize {
+			}
+			nextSweep = time.Now().Add(d.cfg.SweepInterval)
+		}
+
+		wait := d.cfg.MaxPollInterval
+		if len(ids) > 0 {
+			wait = cdcLinger
+		}
+		nctx, cancel := context.WithTimeout(ctx, wait)
+		txIDs, p, err := b.Next(nctx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+			if len(ids) == 0 {
+				metrics.OutboxCDCLagMs.Set(0) // 空闲
+			}
+		} else {
+			if len(ids) == 0 && len(txIDs) > 0 {
+				first = time.Now()
+			}
+			ids = append(ids, txIDs...)
+			last = p
+			metrics.OutboxCDCLagMs.Set(max(time.Since(p.Time).Milliseconds(), 0))
+
+			full := len(ids) >= d.cfg.BatchSize
+			stale := len(ids) > 0 && time.Since(first) >= d.cfg.PollInterval
+			idleSave := len(ids) == 0 && time.Since(savedAt) >= cdcSaveEvery
+			if !full && !stale && !idleSave {
+				continue
+			}
+		}
+
+		if err := d.relayBatch(ctx, b, ids); err != nil {
+			return err
+		}
+		ids = ids[:0]
+
+		if last.File != saved.File || last.Pos != saved.Pos {
+			if err := b.Save(ctx, last); err != nil {
+				return fmt.Errorf("save binlog position: %w", err)
+			}
+			saved, savedAt = last, time.Now()
+		}
+	}
+}
+
+// relayBatch 回表读取仍为 pending 的行并按 key 有序发布；失败的行记一次失败后交给轮询，
+// 同 key 的后续行不发送、同样留给轮询，其他 key 照常发布。返回 nil 时这批已经处理完，可以推进位点
+func (d *OutboxDispatcher) relayBatch(ctx context.Context, b binlogSource, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := b.Load(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	errs := d.publish(ctx, rows)
+
+	// 进程退出时也要把已经 ack 的行标记掉
+	markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	sent := make([]uint64, 0, len(rows))
+	for i, r := range rows {
+		switch {
+		case errs[i] == nil:
+			sent = append(sent, r.ID)
+		case errors.Is(errs[i], kafka.ErrSkipped):
+			// 排在失败行后面的同 key 行：没有发出，留在 pending 里由轮询按顺序补发
+		default:
+			metrics.OutboxFailedTotal.Add(1)
+			if ctx.Err() != nil {
+				continue // 进程退出导致的失败不计入重试次数，位点也不会推进
+			}
+			// 中继的行没领过租约（owner 为空）；MarkFailed 失败时行仍是 pending、没有退避，轮询下一次就会领到
+			_, _ = d.fail(markCtx, "", r, errs[i])
+		}
+	}
+	if err := d.store.MarkSent(markCtx, "", sent); err != nil {
+		// 位点没推进：重启或回退到轮询后会重复发布（至少一次语义）
+		return fmt.Errorf("mark sent: %w", err)
+	}
+	metrics.OutboxSentTotal.Add(int64(len(sent)))
+	return ctx.Err()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+)
+
+// fakeBinlog：Next 依次执行 txs（插入 outbox 行并返回 id），用完后阻塞到 ctx 结束
+type fakeBinlog struct {
+	db       *sql.DB
+	startErr error
+	txs      []func() []uint64
+
+	mu     sync.Mutex
+	starts int
+	nexts  int
+	saved  []mysql.BinlogPosition
+}
+
+func (b *fakeBinlog) Start(context.Context) (mysql.BinlogPosition, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.starts++
+	return mysql.BinlogPosition{File: "binlog.000001", Pos: 4}, b.startErr
+}
+
+func (b *fakeBinlog) Next(ctx context.Context) ([]uint64, mysql.BinlogPosition, error) {
+	b.mu.Lock()
+	n := b.nexts
+	b.nexts++
+	b.mu.Unlock()
+	if n >= len(b.txs) {
+		<-ctx.Done()
+		return nil, mysql.BinlogPosition{}, ctx.Err()
+	}
+	return b.txs[n](), mysql.BinlogPosition{File: "binlog.000001", Pos: uint32(100 * (n + 1)), Time: time.Now()}, nil
+}
+
+func (b *fakeBinlog) Load(ctx context.Context, ids []uint64) ([]event.OutboxRecord, error) {
+	var res []event.OutboxRecord
+	for _, id := range ids {
+		var r event.OutboxRecord
+		err := b.db.QueryRowContext(ctx, `SELECT id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'), attempts
+FROM outbox WHERE status = 'pending' AND id = ?`, id).Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.Attempts)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (b *fakeBinlog) Save(_ context.Context, pos mysql.BinlogPosition) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.saved = append(b.saved, pos)
+	return nil
+}
+
+func (b *fakeBinlog) Close() error { return nil }
+
+func TestRelayHandsFailedKeyToPoller(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	db, store := openOutbox(t)
+
+	var a1, b1, a2 uint64
+	pub := &fakePublisher{}
+	bl := &fakeBinlog{db: db, txs: []func() []uint64{func() []uint64 {
+		ids := addRows(t, db, store, "a", "b", "a")
+		a1, b1, a2 = ids[0], ids[1], ids[2]
+		pub.mu.Lock()
+		pub.fail = map[string]error{cloudeventsID(a1): io.ErrUnexpectedEOF}
+		pub.mu.Unlock()
+		return ids
+	}}}
+	d := newTestDispatcher(store, pub, DispatcherConfig{BatchSize: 3, SweepInterval: time.Hour})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = d.relayBinlog(ctx, bl)
+	}()
+	// 等位点保存后停止
+	for {
+		bl.mu.Lock()
+		saved := len(bl.saved)
+		bl.mu.Unlock()
+		if saved > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("binlog position never saved")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	// 失败的 a1 不挡住 b，只挡住同 key 的 a2；位点照常推进
+	attempted, published := pub.snapshot()
+	if !equalStrings(attempted, ids2str(a1, b1)) || !equalStrings(published, ids2str(b1)) {
+		t.Fatalf("attempted %v, published %v", attempted, published)
+	}
+	if bl.saved[0].Pos != 100 {
+		t.Errorf("saved position %+v, want pos 100", bl.saved[0])
+	}
+	if st := stateOf(t, db, a1); st.status != "pending" || st.attempts != 1 || !st.backoff {
+		t.Errorf("a1 = %+v, want pending in backoff after 1 attempt", st)
+	}
+	if st := stateOf(t, db, a2); st.status != "pending" || st.attempts != 0 {
+		t.Errorf("a2 = %+v, want untouched pending", st)
+	}
+	if st := stateOf(t, db, b1); st.status != "sent" {
+		t.Errorf("b1 = %+v, want sent", st)
+	}
+
+	// 轮询接手：退避结束后 a1、a2 按顺序发出
+	pub.reset()
+	endBackoff(t, db)
+	d.drainOnce(context.Background())
+	if _, published := pub.snapshot(); !equalStrings(published, ids2str(a1, a2)) {
+		t.Errorf("poller published %v, want %v", published, ids2str(a1, a2))
+	}
+}
+
+// 没抢到中继锁的副本只待命：不订阅、不轮询，定期重试抢锁
+func TestCDCStandbyStaysIdle(t *testing.T) {
+	db, store := openOutbox(t)
+	ids := addRows(t, db, store, "a", "b")
+
+	bl := &fakeBinlog{db: db, startErr: mysql.ErrBinlogLocked}
+	pub := &fakePublisher{}
+	d := newTestDispatcher(store, pub, DispatcherConfig{RetryInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	d.runCDC(ctx, bl)
+
+	if bl.starts < 2 {
+		t.Errorf("Start called %d times, want retries every RetryInterval", bl.starts)
+	}
+	if attempted, _ := pub.snapshot(); len(attempted) != 0 {
+		t.Errorf("standby published %v", attempted)
+	}
+	for _, id := range ids {
+		var claimed sql.NullString
+		if err := db.QueryRow(`SELECT claimed_by FROM outbox WHERE id = ?`, id).Scan(&claimed); err != nil {
+			t.Fatal(err)
+		}
+		if st := stateOf(t, db, id); st.status != "pending" || claimed.Valid {
+			t.Errorf("row %d = %+v claimed by %q, want untouched", id, st, claimed.String)
+		}
+	}
+}
+
+var _ binlogSource = (*mysql.OutboxBinlog)(nil)
@@ -2,74 +2,272 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kgo"
 
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
 	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
 	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
 )
 
+// DispatcherConfig：Owner 在所有 worker 副本间唯一，Lease 要明显长于一批消息的发布耗时
+// 单行发布失败按 RetryBaseDelay 指数退避（上限 RetryMaxDelay），失败 MaxAttempts 次后进入 dead
+type DispatcherConfig struct {
+	Owner string
+	Lease time.Duration
+
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// 每批最多领取 BatchSize 行；空闲时轮询间隔从 PollInterval 逐步退避到 MaxPollInterval
+	BatchSize       int
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+
+	// Lanes > 0 时切到分区投递：按 key 哈希分到 N 条有序 lane 并行发布（见 outbox_lanes.go）
+	Lanes int
+
+	// 投递时统一编码成 CloudEvents：Source 标识事件来源，Encoding 选 binary / structured
+	Source   string
+	Encoding cloudevents.Mode
+
+	// Wakeup 有信号时立即领取，不等轮询间隔；nil 则只靠轮询
+	Wakeup <-chan struct{}
+
+	// Binlog 非 nil 时切到 binlog 中继（见 outbox_cdc.go），不可用时回退到轮询
+	Binlog        *mysql.OutboxBinlog
+	RetryInterval time.Duration // binlog 出错后轮询多久再重试订阅
+	SweepInterval time.Duration // 中继期间补发失败 / 遗留行的轮询间隔
+}
+
+// recordPublisher：*kafka.Producer，测试里替换成假的
+type recordPublisher interface {
+	PublishOrdered(ctx context.Context, recs []*kgo.Record) []error
+	PublishRaw(ctx context.Context, rec *kgo.Record) error
+}
+
 type OutboxDispatcher struct {
 	log   *slog.Logger
-	store *mysql.OutboxStore
-	kpub  *kafka.Producer
+	store event.OutboxSource
+	kpub  recordPublisher
+	cfg   DispatcherConfig
+}
+
+func NewOutboxDispatcher(log *slog.Logger, store event.OutboxSource, kpub recordPublisher, cfg DispatcherConfig) *OutboxDispatcher {
+	return &OutboxDispatcher{log: log, store: store, kpub: kpub, cfg: cfg}
 }
 
-func NewOutboxDispatcher(log *slog.Logger, store *mysql.OutboxStore, kpub *kafka.Producer) *OutboxDispatcher {
-	return &OutboxDispatcher{log: log, store: store, kpub: kpub}
+// instanceID：hostname-pid-随机后缀，重启后不会误认上一个进程的租约
+func instanceID() string {
+	host, _ := os.Hostname()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b[:]))
 }
 
+// Run 默认自适应轮询：一批取满说明还有积压，立刻再取；取空则间隔翻倍退避到 MaxPollInterval
+// 事务提交后的唤醒信号会打断等待，轮询只作为漏掉通知时的兜底
 func (d *OutboxDispatcher) Run(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	if d.cfg.Binlog != nil {
+		d.runCDC(ctx, d.cfg.Binlog)
+		return
+	}
+	d.runPoll(ctx)
+}
 
+func (d *OutboxDispatcher) runPoll(ctx context.Context) {
+	if d.cfg.Lanes > 0 {
+		d.runLanes(ctx)
+		return
+	}
+
+	wait := d.cfg.PollInterval
 	for {
-		select {
-		case <-ctx.Done():
+		n := d.drainOnce(ctx)
+
+		switch {
+		case n >= d.cfg.BatchSize:
+			wait = 0
+		case n > 0:
+			wait = d.cfg.PollInterval
+		default:
+			wait = min(max(wait*2, d.cfg.PollInterval), d.cfg.MaxPollInterval)
+		}
+		if !d.sleep(ctx, &wait) {
 			return
-		case <-ticker.C:
-			d.drainOnce(ctx)
 		}
 	}
 }
 
-func (d *OutboxDispatcher) drainOnce(ctx context.Context) {
-	rows, err := d.store.ListUnsent(ctx, 50)
+// sleep 等待 wait 或被 Wakeup 提前唤醒；被唤醒后空闲退避从头开始。ctx 结束时返回 false
+func (d *OutboxDispatcher) sleep(ctx context.Context, wait *time.Duration) bool {
+	if *wait == 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(*wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+	case <-d.cfg.Wakeup:
+		metrics.OutboxWakeupsTotal.Add(1)
+		*wait = 0
+	}
+	return true
+}
+
+// drainOnce 领取一批、按 key 有序批量发布、一条 UPDATE 标记已发送；返回领取到的行数
+func (d *OutboxDispatcher) drainOnce(ctx context.Context) int {
+	// 只会拿到自己持有租约的行；中途失败的行下一轮由自己续领，崩溃则等租约过期被别人接手
+	rows, err := d.store.Claim(ctx, d.cfg.Owner, d.cfg.Lease, d.cfg.BatchSize)
 	if err != nil {
-		d.log.Error("outbox_list_error", slog.Any("err", err))
-		return
+		d.log.Error("outbox_claim_error", slog.Any("err", err))
+		return 0
+	}
+	if len(rows) == 0 {
+		return 0
 	}
 	metrics.OutboxPolledTotal.Add(int64(len(rows)))
-	for _, r := range rows {
-		// headers json -> []kgo.RecordHeader
-		var hm map[string]string
-		_ = json.Unmarshal(r.Headers, &hm)
-		var hs []kgo.RecordHeader
-		for k, v := range hm {
-			hs = append(hs, kgo.RecordHeader{Key: k, Value: []byte(v)})
+
+	errs := d.publish(ctx, rows)
+
+	// 进程退出时也要把已经 ack 的行标记掉，否则重启后全部重发
+	markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	sent := make([]uint64, 0, len(rows))
+	for i, r := range rows {
+		if errs[i] == nil {
+			sent = append(sent, r.ID)
+			continue
+		}
+		if errors.Is(errs[i], kafka.ErrSkipped) {
+			// 同 key 前面的行失败了，这条没有发出：留在 pending 里，
+			// Claim 会等失败的那条重试之后再按顺序领回
+			continue
 		}
+		metrics.OutboxFailedTotal.Add(1)
+		if ctx.Err() != nil {
+			continue // 进程退出导致的失败不计入重试次数，租约到期后重新领取
+		}
+		_, _ = d.fail(markCtx, d.cfg.Owner, r, errs[i])
+	}
+
+	if err := d.store.MarkSent(markCtx, d.cfg.Owner, sent); err != nil {
+		// 已经发出去了，租约到期后会重复发布（至少一次语义）；ErrLeaseLost 时别人已经领走、会再发一次
+		d.log.Error("outbox_mark_sent_error", slog.Int("rows", len(sent)), slog.Any("err", err))
+		metrics.OutboxFailedTotal.Add(int64(len(sent)))
+		return len(rows)
+	}
+	metrics.OutboxSentTotal.Add(int64(len(sent)))
+	return len(rows)
+}
 
-		err := d.kpub.PublishRaw(ctx, &kgo.Record{
-			Topic:   r.Topic,
-			Key:     []byte(r.MsgKey),
-			Value:   r.Payload,
-			Headers: hs,
-		})
+// toRecord 把 outbox 行编码成 CloudEvents；编码失败（headers 损坏等）是永久错误
+func (d *OutboxDispatcher) toRecord(r event.OutboxRecord) (*kgo.Record, error) {
+	e, err := cloudevents.FromOutbox(r, d.cfg.Source)
+	if err != nil {
+		return nil, err
+	}
+	value, hs, err := cloudevents.Encode(e, d.cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &kgo.Record{
+		Topic:   r.Topic,
+		Key:     []byte(r.MsgKey),
+		Value:   value,
+		Headers: hs,
+	}, nil
+}
+
+// publish 按 key 有序批量发布（见 kafka.Producer.PublishOrdered），errs[i] 对应 rows[i]；
+// 编码失败的行带回编码错误，同一批里排在它后面的同 key 行不发送，记为 kafka.ErrSkipped
+func (d *OutboxDispatcher) publish(ctx context.Context, rows []event.OutboxRecord) []error {
+	errs := make([]error, len(rows))
+	recs := make([]*kgo.Record, 0, len(rows))
+	idx := make([]int, 0, len(rows))
+	held := make(map[string]bool)
+	for i, r := range rows {
+		if held[r.MsgKey] {
+			errs[i] = kafka.ErrSkipped
+			continue
+		}
+		rec, err := d.toRecord(r)
 		if err != nil {
-			d.log.Error("outbox_publish_error", slog.Uint64("id", r.ID), slog.Any("err", err))
-			metrics.OutboxFailedTotal.Add(1)
-			return // 停住，等下一轮重试
+			errs[i] = err
+			if r.MsgKey != "" {
+				held[r.MsgKey] = true
+			}
+			continue
 		}
+		recs = append(recs, rec)
+		idx = append(idx, i)
+	}
+	for j, err := range d.kpub.PublishOrdered(ctx, recs) {
+		errs[idx[j]] = err
+	}
+	return errs
+}
 
-		if err := d.store.MarkSent(ctx, r.ID); err != nil {
-			d.log.Error("outbox_mark_sent_error", slog.Uint64("id", r.ID), slog.Any("err", err))
-			metrics.OutboxFailedTotal.Add(1)
-			return
-		}
-		metrics.OutboxSentTotal.Add(1)
+// fail 记录一次发布失败：退避后重试，次数用完进入 dead；owner 是这一行的租约持有者
+func (d *OutboxDispatcher) fail(ctx context.Context, owner string, r event.OutboxRecord, cause error) (dead bool, retryIn time.Duration) {
+	attempts := r.Attempts + 1
+	dead = attempts >= d.cfg.MaxAttempts || errors.Is(cause, cloudevents.ErrInvalid) // 编码错误重试也不会好
+	retryIn = d.backoff(attempts)
+
+	if err := d.store.MarkFailed(ctx, owner, r.ID, errText(cause), retryIn, dead); err != nil {
+		// 状态没写进去（或租约已被别人接手）：下次领取时按原次数重试
+		d.log.Error("outbox_mark_failed_error", slog.Uint64("id", r.ID), slog.Any("err", err))
+		return false, retryIn
+	}
+
+	if dead {
+		metrics.OutboxDeadTotal.Add(1)
+		d.log.Error("outbox_dead",
+			slog.Uint64("id", r.ID),
+			slog.String("key", r.MsgKey),
+			slog.String("type", r.Type),
+			slog.Int("attempts", attempts),
+			slog.Any("err", cause),
+		)
+		return true, retryIn
+	}
+	d.log.Warn("outbox_publish_error",
+		slog.Uint64("id", r.ID),
+		slog.Int("attempts", attempts),
+		slog.Duration("retry_in", retryIn),
+		slog.Any("err", cause),
+	)
+	return false, retryIn
+}
+
+func (d *OutboxDispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.RetryBaseDelay
+	for i := 1; i < attempts && delay < d.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.RetryMaxDelay)
+}
+
+// errText：last_error 列最长 1024，截断时不切坏 UTF-8
+func errText(err error) string {
+	s := err.Error()
+	if len(s) <= 1024 {
+		return s
 	}
+	return strings.ToValidUTF8(s[:1024], "")
 }
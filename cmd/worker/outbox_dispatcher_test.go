@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlite"
+)
+
+// fakePublisher 按 PublishOrdered 的约定工作：同 (topic, key) 前一条失败后剩下的记为 ErrSkipped
+type fakePublisher struct {
+	mu        sync.Mutex
+	fail      map[string]error // 事件 id -> 发布错误
+	published []string         // 发布成功的事件 id，按发布顺序
+	attempted []string
+
+	// gate 非 nil 时，key 在其中的消息发布前先等 gate 关闭（模拟慢分区）
+	gate     chan struct{}
+	gateKeys map[string]bool
+}
+
+func (p *fakePublisher) PublishOrdered(ctx context.Context, recs []*kgo.Record) []error {
+	errs := make([]error, len(recs))
+	failed := make(map[string]bool)
+	for i, rec := range recs {
+		chain := rec.Topic + "/" + string(rec.Key)
+		if len(rec.Key) > 0 && failed[chain] {
+			errs[i] = kafka.ErrSkipped
+			continue
+		}
+		if errs[i] = p.PublishRaw(ctx, rec); errs[i] != nil {
+			failed[chain] = true
+		}
+	}
+	return errs
+}
+
+func (p *fakePublisher) PublishRaw(ctx context.Context, rec *kgo.Record) error {
+	if p.gate != nil && p.gateKeys[string(rec.Key)] {
+		select {
+		case <-p.gate:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	e, err := cloudevents.Decode(rec, nil)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempted = append(p.attempted, e.ID)
+	if err := p.fail[e.ID]; err != nil {
+		return err
+	}
+	p.published = append(p.published, e.ID)
+	return nil
+}
+
+func (p *fakePublisher) snapshot() (attempted, published []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.attempted...), append([]string(nil), p.published...)
+}
+
+func (p *fakePublisher) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail, p.attempted, p.published = nil, nil, nil
+}
+
+func openOutbox(t *testing.T) (*sql.DB, *sqlite.OutboxStore) {
+	t.Helper()
+	db, err := sqlite.Open(sqlite.Config{DSN: "file::memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	m, err := sqlite.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db, sqlite.NewOutboxStore(db)
+}
+
+// addRows 按顺序插入，返回自增的 id
+func addRows(t *testing.T, db *sql.DB, s *sqlite.OutboxStore, keys ...string) []uint64 {
+	t.Helper()
+	ids := make([]uint64, len(keys))
+	for i, k := range keys {
+		err := s.Add(context.Background(), event.OutboxMessage{
+			Topic: "user.events", Key: k, Type: "UserCreated", Payload: map[string]string{"key": k},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.QueryRow(`SELECT MAX(id) FROM outbox`).Scan(&ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+type rowState struct {
+	status   string
+	attempts int
+	backoff  bool // next_attempt_at 在未来
+}
+
+func stateOf(t *testing.T, db *sql.DB, id uint64) rowState {
+	t.Helper()
+	var st rowState
+	err := db.QueryRow(`SELECT status, attempts, COALESCE(next_attempt_at > strftime('%Y-%m-%d %H:%M:%f', 'now'), 0) FROM outbox WHERE id = ?`, id).
+		Scan(&st.status, &st.attempts, &st.backoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// endBackoff 让退避中的行立即可以重试
+func endBackoff(t *testing.T, db *sql.DB) {
+	t.Helper()
+	if _, err := db.Exec(`UPDATE outbox SET next_attempt_at = NULL`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestDispatcher(store event.OutboxSource, pub recordPublisher, cfg DispatcherConfig) *OutboxDispatcher {
+	if cfg.Owner == "" {
+		cfg.Owner = "test"
+	}
+	cfg.Lease = time.Minute
+	cfg.MaxAttempts = 5
+	cfg.RetryBaseDelay = time.Minute
+	cfg.RetryMaxDelay = time.Hour
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 10
+	}
+	cfg.PollInterval = 5 * time.Millisecond
+	cfg.MaxPollInterval = 20 * time.Millisecond
+	cfg.Source = "/test"
+	return NewOutboxDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), store, pub, cfg)
+}
+
+func ids2str(ids ...uint64) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = cloudeventsID(id)
+	}
+	return s
+}
+
+func cloudeventsID(id uint64) string {
+	e, _ := cloudevents.FromOutbox(event.OutboxRecord{ID: id, Type: "T"}, "/test")
+	return e.ID
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDrainOnceHoldsKeyAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	db, store := openOutbox(t)
+	ids := addRows(t, db, store, "a", "a", "b", "a")
+	a1, a2, b1, a3 := ids[0], ids[1], ids[2], ids[3]
+
+	pub := &fakePublisher{fail: map[string]error{cloudeventsID(a1): io.ErrUnexpectedEOF}}
+	d := newTestDispatcher(store, pub, DispatcherConfig{})
+
+	if n := d.drainOnce(ctx); n != 4 {
+		t.Fatalf("drainOnce claimed %d rows, want 4", n)
+	}
+	attempted, published := pub.snapshot()
+	// a1 失败后 a2 / a3 不能发出，否则重试后 consumer 会看到 a2, a1, a2
+	if !equalStrings(attempted, ids2str(a1, b1)) || !equalStrings(published, ids2str(b1)) {
+		t.Fatalf("attempted %v, published %v", attempted, published)
+	}
+	if st := stateOf(t, db, a1); st.status != "pending" || st.attempts != 1 || !st.backoff {
+		t.Errorf("a1 = %+v, want pending in backoff after 1 attempt", st)
+	}
+	for _, id := range []uint64{a2, a3} {
+		if st := stateOf(t, db, id); st.status != "pending" || st.attempts != 0 {
+			t.Errorf("row %d = %+v, want untouched pending", id, st)
+		}
+	}
+	if st := stateOf(t, db, b1); st.status != "sent" {
+		t.Errorf("b1 = %+v, want sent", st)
+	}
+
+	// a1 退避期间同 key 的后续行不会被领取
+	pub.reset()
+	if n := d.drainOnce(ctx); n != 0 {
+		t.Errorf("drainOnce during backoff claimed %d rows", n)
+	}
+
+	// 退避结束后按原顺序补发
+	endBackoff(t, db)
+	d.drainOnce(ctx)
+	if _, published := pub.snapshot(); !equalStrings(published, ids2str(a1, a2, a3)) {
+		t.Errorf("retry published %v, want %v", published, ids2str(a1, a2, a3))
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// JanitorConfig：Retention 之前已发送的行分批删除；ArchiveDir 非空时先写 gzip NDJSON 再删
+// BatchSize + BatchPause 控制删除速率，避免大事务拖慢从库复制
+type JanitorConfig struct {
+	Retention  time.Duration
+	Interval   time.Duration
+	BatchSize  int
+	BatchPause time.Duration
+	MaxPerRun  int // 单轮最多删除多少行，0 不限
+	ArchiveDir string
+}
+
+// OutboxJanitor：outbox 保留期清理
+// 多个 worker 副本同时开启时删除是幂等的，但归档文件里可能出现重复行（按 id 去重即可）
+type OutboxJanitor struct {
+	log   *slog.Logger
+	store event.OutboxRetention
+	cfg   JanitorConfig
+}
+
+func NewOutboxJanitor(log *slog.Logger, store event.OutboxRetention, cfg JanitorConfig) *OutboxJanitor {
+	return &OutboxJanitor{log: log, store: store, cfg: cfg}
+}
+
+func (j *OutboxJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		j.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *OutboxJanitor) runOnce(ctx context.Context) {
+	before := time.Now().Add(-j.cfg.Retention)
+
+	var arc *archive
+	defer func() {
+		if arc != nil {
+			if err := arc.Close(); err != nil {
+				j.log.Error("outbox_archive_close_error", slog.Any("err", err))
+			}
+		}
+	}()
+
+	var purged int64
+	for j.cfg.MaxPerRun <= 0 || purged < int64(j.cfg.MaxPerRun) {
+		rows, err := j.store.ListSentBefore(ctx, before, j.cfg.BatchSize)
+		if err != nil {
+			j.log.Error("outbox_purge_list_error", slog.Any("err", err))
+			break
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if j.cfg.ArchiveDir != "" {
+			if arc == nil {
+				if arc, err = openArchive(j.cfg.ArchiveDir); err != nil {
+					j.log.Error("outbox_archive_open_error", slog.Any("err", err))
+					break
+				}
+			}
+			// 归档落盘成功才删，失败就停下等下一轮
+			if err := arc.Write(rows); err != nil {
+				j.log.Error("outbox_archive_write_error", slog.Any("err", err))
+				break
+			}
+			metrics.OutboxArchivedTotal.Add(int64(len(rows)))
+		}
+
+		ids := make([]uint64, len(rows))
+		for i, r := range rows {
+			ids[i] = r.ID
+		}
+		n, err := j.store.Delete(ctx, ids)
+		if err != nil {
+			j.log.Error("outbox_purge_delete_error", slog.Any("err", err))
+			break
+		}
+		purged += n
+		metrics.OutboxPurgedTotal.Add(n)
+
+		if len(rows) < j.cfg.BatchSize {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.cfg.BatchPause):
+		}
+	}
+
+	if purged > 0 {
+		j.log.Info("outbox_purged", slog.Int64("rows", purged), slog.Time("before", before))
+	}
+
+	st, err := j.store.Stats(ctx)
+	if err != nil {
+		j.log.Error("outbox_stats_error", slog.Any("err", err))
+		return
+	}
+	metrics.OutboxTableRows.Set(st.Rows)
+	metrics.OutboxTableBytes.Set(st.Bytes)
+	metrics.OutboxDeadRows.Set(st.Dead)
+}
+
+// archive：一轮清理写一个 outbox-<时间>.ndjson.gz，每行一条消息
+type archive struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+type archivedRecord struct {
+	ID        uint64          `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Headers   json.RawMessage `json:"headers"`
+	CreatedAt time.Time       `json:"created_at"`
+	SentAt    time.Time       `json:"sent_at"`
+}
+
+func openArchive(dir string) (*archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("outbox-%s.ndjson.gz", time.Now().UTC().Format("20060102T150405.000Z"))
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &archive{f: f, gz: gzip.NewWriter(f)}, nil
+}
+
+func (a *archive) Write(rows []event.OutboxRecord) error {
+	enc := json.NewEncoder(a.gz)
+	for _, r := range rows {
+		if err := enc.Encode(archivedRecord{
+			ID:        r.ID,
+			Topic:     r.Topic,
+			Key:       r.MsgKey,
+			Type:      r.Type,
+			Payload:   json.RawMessage(r.Payload),
+			Headers:   json.RawMessage(r.Headers),
+			CreatedAt: r.CreatedAt,
+			SentAt:    r.SentAt,
+		}); err != nil {
+			return err
+		}
+	}
+	// 刷到磁盘后再返回，保证删库之前归档已经持久化
+	if err := a.gz.Flush(); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
+
+func (a *archive) Close() error {
+	if err := a.gz.Close(); err != nil {
+		_ = a.f.Close()
+		return err
+	}
+	return a.f.Close()
+}
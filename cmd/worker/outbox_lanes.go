@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/kafka"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// 分区投递模式（Lanes > 0）：
+//   - claimer 按批领取，按 msg_key 哈希分到 N 条 lane，同 key 永远进同一条 lane
+//   - 每条 lane 把排队的行攒成一批，按 key 有序批量发布（同 drainOnce，见 kafka.Producer.PublishOrdered）；
+//     某个 key 失败只挡住该 key 的后续消息，lane 里其他 key 继续
+//   - 已发送的 id 汇总后批量 MarkSent，标记完成前一直算在途，不会被重复派发
+//
+// 在途的行仍会被自己的下一次 Claim 领回（顺带续租约），按 id 去重后跳过；
+// Claim 的条数按 BatchSize + 在途数 + 上一轮放回的行数算，在途和放回的行不会占满领取窗口。
+// claimer 从不阻塞在 lane 上：某条 lane 满了，本轮剩下分到它的行都放回（保持同 key 顺序），
+// 一条慢 lane 不会拖住其他 lane
+func (d *OutboxDispatcher) runLanes(ctx context.Context) {
+	ld := &laneDispatcher{
+		d:        d,
+		inflight: make(map[uint64]struct{}),
+		sent:     make(chan uint64, d.cfg.BatchSize),
+	}
+	ld.lanes = make([]*lane, d.cfg.Lanes)
+	for i := range ld.lanes {
+		ld.lanes[i] = newLane(i, d.cfg.BatchSize)
+	}
+
+	var lanesWG, markerWG sync.WaitGroup
+	for _, l := range ld.lanes {
+		lanesWG.Add(1)
+		go func() {
+			defer lanesWG.Done()
+			ld.runLane(ctx, l)
+		}()
+	}
+	markerWG.Add(1)
+	go func() {
+		defer markerWG.Done()
+		ld.runMarker()
+	}()
+
+	ld.runClaimer(ctx)
+
+	for _, l := range ld.lanes {
+		close(l.ch)
+	}
+	lanesWG.Wait()
+	close(ld.sent) // lane 全部退出后再关，marker 把剩下的 id 标记完
+	markerWG.Wait()
+}
+
+type laneDispatcher struct {
+	d     *OutboxDispatcher
+	lanes []*lane
+
+	mu       sync.Mutex
+	inflight map[uint64]struct{} // 已派发、尚未标记完成的 id
+
+	parked int // 上一轮因为 lane 满了放回的行数（只有 claimer 读写）
+
+	sent chan uint64
+}
+
+type lane struct {
+	ch chan event.OutboxRecord
+
+	// key -> 第一条没发出的行的 id 和截止时间：期间该 key 更新的行直接丢回（下次 Claim 会按顺序再领），
+	// 那一行被重新领回或到期后恢复
+	blocked map[string]blockedKey
+
+	depth    *expvar.Int
+	holAgeMs *expvar.Int
+}
+
+type blockedKey struct {
+	id    uint64
+	until time.Time
+}
+
+func newLane(idx, size int) *lane {
+	l := &lane{
+		ch:       make(chan event.OutboxRecord, size),
+		blocked:  make(map[string]blockedKey),
+		depth:    new(expvar.Int),
+		holAgeMs: new(expvar.Int),
+	}
+	key := strconv.Itoa(idx)
+	metrics.OutboxLaneDepth.Set(key, l.depth)
+	metrics.OutboxLaneHOLAgeMs.Set(key, l.holAgeMs)
+	return l
+}
+
+func (ld *laneDispatcher) runClaimer(ctx context.Context) {
+	cfg := ld.d.cfg
+	wait := cfg.PollInterval
+	for {
+		n, backlog := ld.claimOnce(ctx)
+
+		switch {
+		case n >= cfg.BatchSize:
+			wait = 0
+		case n > 0 || backlog:
+			wait = cfg.PollInterval
+		default:
+			wait = min(max(wait*2, cfg.PollInterval), cfg.MaxPollInterval)
+		}
+		if !ld.d.sleep(ctx, &wait) {
+			return
+		}
+	}
+}
+
+// claimOnce 返回新派发的行数，以及是否有行因为 lane 满了没派发
+func (ld *laneDispatcher) claimOnce(ctx context.Context) (int, bool) {
+	d := ld.d
+	ld.mu.Lock()
+	// 放回的行排在前面还会被领回；最多按每条 lane 一批算，积压再多也不会一次领太多
+	limit := d.cfg.BatchSize + len(ld.inflight) + min(ld.parked, d.cfg.BatchSize*len(ld.lanes))
+	ld.mu.Unlock()
+	rows, err := d.store.Claim(ctx, d.cfg.Owner, d.cfg.Lease, limit)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.log.Error("outbox_claim_error", slog.Any("err", err))
+		}
+		return 0, false
+	}
+
+	n, parked := 0, 0
+	full := make(map[int]bool) // 本轮已经满了的 lane
+	defer func() { ld.parked = parked }()
+	for _, r := range rows {
+		idx := ld.laneOf(r)
+		if full[idx] {
+			parked++
+			continue // 不派发：前面同 lane 的行已经放回，后面的也要放回，否则同 key 会乱序
+		}
+
+		ld.mu.Lock()
+		_, busy := ld.inflight[r.ID]
+		if !busy {
+			ld.inflight[r.ID] = struct{}{}
+		}
+		ld.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		l := ld.lanes[idx]
+		select {
+		case l.ch <- r:
+			l.depth.Set(int64(len(l.ch)))
+			n++
+		default:
+			// lane 满了：这一行留在租约里，下一轮再领
+			full[idx] = true
+			parked++
+			ld.done(r.ID)
+		}
+	}
+	metrics.OutboxPolledTotal.Add(int64(n))
+	return n, len(full) > 0
+}
+
+// laneOf：同 key 同 lane；没有 key 的消息不要求顺序，按 id 打散
+func (ld *laneDispatcher) laneOf(r event.OutboxRecord) int {
+	if r.MsgKey == "" {
+		return int(r.ID % uint64(len(ld.lanes)))
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(r.MsgKey))
+	return int(h.Sum32() % uint32(len(ld.lanes)))
+}
+
+func (ld *laneDispatcher) runLane(ctx context.Context, l *lane) {
+	batch := make([]event.OutboxRecord, 0, max(ld.d.cfg.BatchSize, 1))
+	for r := range l.ch {
+		// 攒批：连同 lane 里已经排队的行一起发布
+		batch = append(batch[:0], r)
+	fill:
+		for len(batch) < cap(batch) {
+			select {
+			case r, ok := <-l.ch:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, r)
+			default:
+				break fill
+			}
+		}
+		l.depth.Set(int64(len(l.ch)))
+
+		ld.publishBatch(ctx, l, batch)
+		if len(l.ch) == 0 {
+			l.holAgeMs.Set(0)
+		}
+	}
+	l.depth.Set(0)
+	l.holAgeMs.Set(0)
+}
+
+// publishBatch 跳过被挡住的 key，其余按 key 有序批量发布；已发送的交给 marker，其余放回
+func (ld *laneDispatcher) publishBatch(ctx context.Context, l *lane, batch []event.OutboxRecord) {
+	d := ld.d
+	if ctx.Err() != nil {
+		for _, r := range batch {
+			ld.done(r.ID) // 退出中：不再发布，租约到期后重新领取
+		}
+		return
+	}
+	l.holAgeMs.Set(time.Since(batch[0].CreatedAt).Milliseconds())
+
+	rows := make([]event.OutboxRecord, 0, len(batch))
+	for _, r := range batch {
+		if b, ok := l.blocked[r.MsgKey]; ok && r.MsgKey != "" {
+			switch {
+			case time.Now().After(b.until) || r.ID == b.id:
+				delete(l.blocked, r.MsgKey) // 到期 / 挡住的那条被重新领回，恢复该 key
+			case r.ID > b.id:
+				ld.done(r.ID)
+				continue
+			}
+		}
+		rows = append(rows, r)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	errs := d.publish(ctx, rows)
+	deadKeys := make(map[string]bool) // 本批里失败后进入 dead 的 key
+	for i, r := range rows {
+		switch {
+		case errs[i] == nil:
+			ld.sent <- r.ID
+			continue
+		case errors.Is(errs[i], kafka.ErrSkipped):
+			// 同 key 前面的行失败了，这条没发出；前面那条进了 dead 时 key 不再退避，
+			// 但这条之后已经派发到 lane 的行不能抢在它前面，从这条挡住
+			if deadKeys[r.MsgKey] {
+				delete(deadKeys, r.MsgKey)
+				l.blocked[r.MsgKey] = blockedKey{id: r.ID, until: time.Now().Add(d.cfg.Lease)}
+			}
+		default:
+			metrics.OutboxFailedTotal.Add(1)
+			if ctx.Err() != nil {
+				break // 进程退出导致的失败不计入重试次数，租约到期后重新领取
+			}
+			dead, retryIn := d.fail(ctx, d.cfg.Owner, r, errs[i])
+			switch {
+			case r.MsgKey == "":
+			case dead:
+				deadKeys[r.MsgKey] = true
+			default:
+				l.blocked[r.MsgKey] = blockedKey{id: r.ID, until: time.Now().Add(retryIn)}
+			}
+		}
+		ld.done(r.ID)
+	}
+}
+
+// runMarker 攒一批已发送的 id 再一条 UPDATE 标记；标记完成后才从在途集合移除
+func (ld *laneDispatcher) runMarker() {
+	const flushEvery = 50 * time.Millisecond
+	batch := make([]uint64, 0, ld.d.cfg.BatchSize)
+	ticker := time.NewTicker(flushEvery)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// 不跟随 ctx：进程退出时也要把已经 ack 的行标记掉
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := ld.d.store.MarkSent(ctx, ld.d.cfg.Owner, batch)
+		cancel()
+		if err != nil {
+			// 已经发出去了，租约到期后会重复发布（至少一次语义）
+			ld.d.log.Error("outbox_mark_sent_error", slog.Int("rows", len(batch)), slog.Any("err", err))
+			metrics.OutboxFailedTotal.Add(int64(len(batch)))
+		} else {
+			metrics.OutboxSentTotal.Add(int64(len(batch)))
+		}
+		for _, id := range batch {
+			ld.done(id)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case id, ok := <-ld.sent:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, id)
+			if len(batch) >= cap(batch) {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (ld *laneDispatcher) done(id uint64) {
+	ld.mu.Lock()
+	delete(ld.inflight, id)
+	ld.mu.Unlock()
+}
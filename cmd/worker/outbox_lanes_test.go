@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+)
+
+// keysInLanes 返回 n 个 key，前两个落在同一条 lane，其余各自落在不同的 lane
+func keysInLanes(t *testing.T, lanes, n int) []string {
+	t.Helper()
+	ld := &laneDispatcher{lanes: make([]*lane, lanes)}
+	laneOf := func(k string) int { return ld.laneOf(event.OutboxRecord{MsgKey: k}) }
+
+	keys := []string{"k0"}
+	used := map[int]bool{laneOf("k0"): true}
+	for i := 1; len(keys) < n && i < 1000; i++ {
+		k := fmt.Sprintf("k%d", i)
+		switch l := laneOf(k); {
+		case len(keys) == 1 && l == laneOf("k0"):
+			keys = append(keys, k)
+		case len(keys) > 1 && !used[l]:
+			used[l] = true
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) < n {
+		t.Fatalf("could not spread %d keys over %d lanes", n, lanes)
+	}
+	return keys
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// startLanes 在后台跑分区投递，返回停止函数
+func startLanes(d *OutboxDispatcher) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.runPoll(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// publishedOf 按发布顺序返回 ids 中已发布的那些
+func publishedOf(p *fakePublisher, ids ...uint64) []string {
+	_, published := p.snapshot()
+	want := ids2str(ids...)
+	return slices.DeleteFunc(published, func(id string) bool { return !slices.Contains(want, id) })
+}
+
+func TestLanesFailedKeyBlocksOnlyItself(t *testing.T) {
+	db, store := openOutbox(t)
+	keys := keysInLanes(t, 4, 3)
+	a, b, c := keys[0], keys[1], keys[2] // a、b 同一条 lane，c 在另一条
+	ids := addRows(t, db, store, a, b, a, c, a, b)
+	a1, b1, a2, c1, a3, b2 := ids[0], ids[1], ids[2], ids[3], ids[4], ids[5]
+
+	pub := &fakePublisher{fail: map[string]error{cloudeventsID(a1): io.ErrUnexpectedEOF}}
+	d := newTestDispatcher(store, pub, DispatcherConfig{Lanes: 4})
+	stop := startLanes(d)
+	defer stop()
+
+	// a 的第一条失败：同 lane 的 b 和别的 lane 的 c 照常发布
+	waitFor(t, "other keys sent", func() bool {
+		return stateOf(t, db, b1).status == "sent" && stateOf(t, db, b2).status == "sent" && stateOf(t, db, c1).status == "sent"
+	})
+	if st := stateOf(t, db, a1); st.status != "pending" || st.attempts != 1 || !st.backoff {
+		t.Errorf("a1 = %+v, want pending in backoff after 1 attempt", st)
+	}
+	for _, id := range []uint64{a2, a3} {
+		if st := stateOf(t, db, id); st.status != "pending" || st.attempts != 0 {
+			t.Errorf("row %d = %+v, want untouched pending", id, st)
+		}
+	}
+	if got := publishedOf(pub, a1, a2, a3); len(got) != 0 {
+		t.Errorf("key a published %v while a1 is failing", got)
+	}
+
+	// 退避结束后按顺序补发
+	pub.mu.Lock()
+	pub.fail = nil
+	pub.mu.Unlock()
+	endBackoff(t, db)
+	waitFor(t, "key a sent", func() bool { return stateOf(t, db, a3).status == "sent" })
+	if got, want := publishedOf(pub, a1, a2, a3), ids2str(a1, a2, a3); !slices.Equal(got, want) {
+		t.Errorf("key a published %v, want %v", got, want)
+	}
+}
+
+func TestLanesDeadRowReleasesKeyInOrder(t *testing.T) {
+	db, store := openOutbox(t)
+	ids := addRows(t, db, store, "a", "a", "a", "a")
+
+	// 编码错误直接进 dead：同 key 后面的行不再等它，但仍按顺序发布
+	pub := &fakePublisher{fail: map[string]error{cloudeventsID(ids[0]): fmt.Errorf("%w: bad", cloudevents.ErrInvalid)}}
+	d := newTestDispatcher(store, pub, DispatcherConfig{Lanes: 2})
+	stop := startLanes(d)
+	defer stop()
+
+	waitFor(t, "rest of key a sent", func() bool { return stateOf(t, db, ids[3]).status == "sent" })
+	if st := stateOf(t, db, ids[0]); st.status != "dead" {
+		t.Errorf("first row = %+v, want dead", st)
+	}
+	if got, want := publishedOf(pub, ids...), ids2str(ids[1:]...); !slices.Equal(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
+
+func TestLanesSlowLaneDoesNotBlockClaimer(t *testing.T) {
+	db, store := openOutbox(t)
+	keys := keysInLanes(t, 4, 3)
+	slow, fast := keys[0], keys[2] // 不同的 lane
+
+	// 慢 key 的积压排在前面，远超 lane 的容量和一次领取的条数
+	slowIDs := addRows(t, db, store, slow, slow, slow, slow, slow, slow, slow, slow)
+	fastIDs := addRows(t, db, store, fast, fast, fast, fast)
+
+	gate := make(chan struct{})
+	pub := &fakePublisher{gate: gate, gateKeys: map[string]bool{slow: true}}
+	d := newTestDispatcher(store, pub, DispatcherConfig{Lanes: 4, BatchSize: 2})
+	stop := startLanes(d)
+	defer stop()
+
+	waitFor(t, "fast key sent behind a stuck lane", func() bool {
+		return stateOf(t, db, fastIDs[len(fastIDs)-1]).status == "sent"
+	})
+	if got, want := publishedOf(pub, fastIDs...), ids2str(fastIDs...); !slices.Equal(got, want) {
+		t.Errorf("fast key published %v, want %v", got, want)
+	}
+	if got := publishedOf(pub, slowIDs...); len(got) != 0 {
+		t.Errorf("slow key published %v before its lane was released", got)
+	}
+
+	close(gate)
+	waitFor(t, "slow key sent", func() bool { return stateOf(t, db, slowIDs[len(slowIDs)-1]).status == "sent" })
+	if got, want := publishedOf(pub, slowIDs...), ids2str(slowIDs...); !slices.Equal(got, want) {
+		t.Errorf("slow key published %v, want %v", got, want)
+	}
+}
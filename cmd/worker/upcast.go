@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// upcast 按 dataschema 校验 payload 并升级到最新版本，handler 只需要认最新结构；
+// 注册表里没有的类型不归本服务处理，原样放行
+func upcast(reg *eventschema.Registry, e *cloudevents.Event) error {
+	if !reg.Known(e.Type) {
+		return nil
+	}
+	d, data, err := reg.Upcast(e.Type, e.DataSchema, e.Data)
+	if err != nil {
+		metrics.EventsInvalidConsumedTotal.Add(1)
+		return err
+	}
+
+	from := 1 // 没有 dataschema 的旧消息按 v1
+	if _, v, ok := eventschema.ParseURI(e.DataSchema); ok {
+		from = v
+	}
+	if from < d.Version {
+		metrics.EventsUpcastTotal.Add(1)
+	}
+	e.Data, e.DataSchema = data, d.URI()
+	return nil
+}
+
+// legacyUserTypes：引入 CloudEvents 之前 user topic（以及它的 DLQ / 重投）上只有 UserCreated
+func legacyUserTypes(topics ...string) cloudevents.LegacyTypes {
+	m := make(cloudevents.LegacyTypes, len(topics))
+	for _, t := range topics {
+		m[t] = user.EventCreated
+	}
+	return m
+}
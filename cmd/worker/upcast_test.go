@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+)
+
+func testRegistry(t *testing.T) *eventschema.Registry {
+	t.Helper()
+	reg, err := eventschema.NewRegistry(userapp.EventSchemas()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+// legacyRecord：引入 CloudEvents 和 schema 之前 outbox 投递的消息，value 是 payload 原文，header 只有 request_id
+func legacyRecord(payload string) *kgo.Record {
+	return &kgo.Record{
+		Topic:   "user.events",
+		Value:   []byte(payload),
+		Headers: []kgo.RecordHeader{{Key: "request_id", Value: []byte("req-1")}},
+	}
+}
+
+func TestUpcastRecords(t *testing.T) {
+	reg := testRegistry(t)
+	v2 := eventschema.URI("UserCreated", 2)
+
+	current, hs, err := cloudevents.Encode(cloudevents.Event{
+		ID: "1", Source: "/test", Type: "UserCreated", DataSchema: v2,
+		Data: json.RawMessage(`{"id":7,"name":"c","email":"c@x","version":4,"created_at":"2024-05-01T00:00:00Z"}`),
+	}, cloudevents.Binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		rec  *kgo.Record
+		want userCachePayload
+	}{
+		{
+			"baseline payload without version",
+			legacyRecord(`{"id":5,"name":"a","email":"a@x"}`),
+			userCachePayload{ID: 5, Name: "a", Email: "a@x", Version: 1},
+		},
+		{
+			"v1 payload with version and created_at",
+			legacyRecord(`{"id":6,"name":"b","email":"b@x","version":3,"created_at":"2024-04-01T00:00:00Z"}`),
+			userCachePayload{ID: 6, Name: "b", Email: "b@x", Version: 3, CreatedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			"baseline Producer.Publish envelope",
+			&kgo.Record{Topic: "user.events", Value: []byte(`{"type":"UserCreated","key":"8","occurred_at":"2024-03-01T00:00:00Z","payload":{"id":8,"name":"d","email":"d@x"}}`)},
+			userCachePayload{ID: 8, Name: "d", Email: "d@x", Version: 1},
+		},
+		{
+			"current version",
+			&kgo.Record{Topic: "user.events", Value: current, Headers: hs},
+			userCachePayload{ID: 7, Name: "c", Email: "c@x", Version: 4, CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := cloudevents.Decode(tt.rec, legacyUserTypes("user.events"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Type != "UserCreated" {
+				t.Errorf("type = %q, want UserCreated", e.Type)
+			}
+			if err := upcast(reg, &e); err != nil {
+				t.Fatal(err)
+			}
+			if e.DataSchema != v2 {
+				t.Errorf("dataschema = %q, want %q", e.DataSchema, v2)
+			}
+			var p userCachePayload
+			if err := json.Unmarshal(e.Data, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.ID != tt.want.ID || p.Name != tt.want.Name || p.Email != tt.want.Email ||
+				p.Version != tt.want.Version || !p.CreatedAt.Equal(tt.want.CreatedAt) {
+				t.Errorf("payload = %+v, want %+v", p, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpcastInvalid(t *testing.T) {
+	reg := testRegistry(t)
+	e, err := cloudevents.Decode(legacyRecord(`{"id":5,"name":""}`), legacyUserTypes("user.events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := upcast(reg, &e); !errors.Is(err, eventschema.ErrInvalid) {
+		t.Errorf("err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	legacy := legacyUserTypes("user.events")
+
+	e, err := cloudevents.Decode(legacyRecord(`{"id":5,"name":"a","email":"a@x"}`), legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != "" || e.Headers["request_id"] != "req-1" || string(e.Data) != `{"id":5,"name":"a","email":"a@x"}` {
+		t.Errorf("event = %+v", e)
+	}
+
+	// 不认识的 topic 上没有类型可用；value 不是 JSON 的直接拒绝
+	other := legacyRecord(`{"id":5}`)
+	other.Topic = "order.events"
+	if _, err := cloudevents.Decode(other, legacy); !errors.Is(err, cloudevents.ErrInvalid) {
+		t.Errorf("unknown topic err = %v, want ErrInvalid", err)
+	}
+	if _, err := cloudevents.Decode(legacyRecord(`not json`), legacy); !errors.Is(err, cloudevents.ErrInvalid) {
+		t.Errorf("non-JSON err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestUpcastUnknownTypePassesThrough(t *testing.T) {
+	reg := testRegistry(t)
+	e := cloudevents.Event{Type: "OrderPlaced", Data: json.RawMessage(`{"anything":true}`)}
+	if err := upcast(reg, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.DataSchema != "" || string(e.Data) != `{"anything":true}` {
+		t.Errorf("event changed: %+v", e)
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
 	userapp "github.com/hacker4257/go-ddd-template/internal/app/user"
 	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/idgen"
 )
 
 type UserHandler struct {
@@ -25,8 +25,12 @@ type createUserReq struct {
 	Email string `json:"email"`
 }
 
+// userIDPrefix：对外字符串 ID 的前缀，如 usr_01j2k3m4n5p6q
+const userIDPrefix = "usr"
+
 type userResp struct {
 	ID        uint64 `json:"id"`
+	PublicID  string `json:"public_id"` // 字符串形式（可逆编码，不保密），前端建议用这个（JS 数字放不下 64 位）
 	Name      string `json:"name"`
 	Email     string `json:"email"`
 	CreatedAt string `json:"created_at"`
@@ -52,8 +56,8 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	// 数字 ID 和 usr_ 字符串两种形式都接受
+	id, err := idgen.Parse(userIDPrefix, chi.URLParam(r, "id"))
 	if err != nil {
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
@@ -71,6 +75,7 @@ func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
 func toUserResp(u user.User) userResp {
 	return userResp{
 		ID:        u.ID,
+		PublicID:  idgen.Encode(userIDPrefix, u.ID),
 		Name:      u.Name,
 		Email:     u.Email,
 		CreatedAt: u.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
@@ -2,18 +2,85 @@ package auditapp
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/hacker4257/go-ddd-template/internal/app/tx"
 	"github.com/hacker4257/go-ddd-template/internal/domain/audit"
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
 )
 
 type Service struct {
-	repo audit.Repo
+	repo   audit.Repo
+	tx     tx.Transactor
+	inbox  event.Inbox
+	events *eventschema.Registry // 回放时把旧版本 payload 升级到最新版本
 }
 
-func New(repo audit.Repo) *Service {
-	return &Service{repo: repo}
+func New(repo audit.Repo, tx tx.Transactor, inbox event.Inbox, events *eventschema.Registry) *Service {
+	return &Service{repo: repo, tx: tx, inbox: inbox, events: events}
 }
 
-func (s *Service) Record(ctx context.Context, eventType, eventKey string, payload []byte) error {
-	return s.repo.Insert(ctx, eventType, eventKey, payload)
+func (s *Service) Record(ctx context.Context, eventType, eventKey, dataSchema string, payload []byte) error {
+	return s.repo.Insert(ctx, eventType, eventKey, dataSchema, payload)
+}
+
+// RecordOnce：inbox 登记和审计写入在同一个事务里，崩溃或重投都不会少记、多记
+// 返回 false 表示 consumer 已经处理过 eventID（什么都没写）
+func (s *Service) RecordOnce(ctx context.Context, consumer, eventID, eventType, eventKey, dataSchema string, payload []byte) (bool, error) {
+	var first bool
+	err := s.tx.WithinTx(ctx, func(tctx context.Context) error {
+		ok, err := s.inbox.Record(tctx, consumer, eventID)
+		if err != nil {
+			return err
+		}
+		first = ok // 事务可能因死锁整体重试，以最后一次为准
+		if !ok {
+			return nil
+		}
+		return s.repo.Insert(tctx, eventType, eventKey, dataSchema, payload)
+	})
+	if err != nil {
+		return false, err
+	}
+	return first, nil
+}
+
+const replayBatch = 500
+
+// Replay 按 id 升序回放 afterID 之后的审计记录（eventType 为空时回放全部类型，limit <= 0 不限条数），
+// 登记过 schema 的事件先升级到最新版本再交给 fn；升级失败时停在该条，返回的错误里带 id
+func (s *Service) Replay(ctx context.Context, afterID uint64, eventType string, limit int, fn func(audit.Log) error) error {
+	n := 0
+	for {
+		size := replayBatch
+		if limit > 0 {
+			size = min(size, limit-n)
+		}
+		if size <= 0 {
+			return nil
+		}
+
+		logs, err := s.repo.List(ctx, afterID, eventType, size)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			if s.events.Known(l.EventType) {
+				d, data, err := s.events.Upcast(l.EventType, l.DataSchema, l.Payload)
+				if err != nil {
+					return fmt.Errorf("audit log %d: %w", l.ID, err)
+				}
+				l.DataSchema, l.Payload = d.URI(), data
+			}
+			if err := fn(l); err != nil {
+				return err
+			}
+			afterID = l.ID
+			n++
+		}
+		if len(logs) < size {
+			return nil
+		}
+	}
 }
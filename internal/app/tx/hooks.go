@@ -0,0 +1,91 @@
+package tx
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+type hooksKey struct{}
+
+// Hooks：一个事务（或 savepoint 层）上登记的回调，由 Transactor 实现负责触发
+type Hooks struct {
+	mu       sync.Mutex
+	commit   []func(ctx context.Context)
+	rollback []func(ctx context.Context)
+}
+
+// AfterCommit 登记事务提交成功后执行的回调（缓存写入、唤醒 dispatcher、打点等）
+// 不在事务里时立即执行；回调 panic 会被吞掉，不影响调用方
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	h, ok := ctx.Value(hooksKey{}).(*Hooks)
+	if !ok {
+		safeCall(ctx, fn)
+		return
+	}
+	h.mu.Lock()
+	h.commit = append(h.commit, fn)
+	h.mu.Unlock()
+}
+
+// AfterRollback 登记事务回滚后执行的回调；不在事务里时忽略
+func AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	h, ok := ctx.Value(hooksKey{}).(*Hooks)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	h.rollback = append(h.rollback, fn)
+	h.mu.Unlock()
+}
+
+// WithHooks 给 Transactor 实现用：每开一个事务/savepoint 挂一组新的 Hooks
+func WithHooks(ctx context.Context) (context.Context, *Hooks) {
+	h := &Hooks{}
+	return context.WithValue(ctx, hooksKey{}, h), h
+}
+
+// Merge：savepoint 成功释放后，把本层回调并入外层，等最外层提交/回滚再触发
+func (h *Hooks) Merge(child *Hooks) {
+	child.mu.Lock()
+	commit, rollback := child.commit, child.rollback
+	child.commit, child.rollback = nil, nil
+	child.mu.Unlock()
+
+	h.mu.Lock()
+	h.commit = append(h.commit, commit...)
+	h.rollback = append(h.rollback, rollback...)
+	h.mu.Unlock()
+}
+
+// RunCommit / RunRollback 按登记顺序执行；ctx 应为不带事务的外层 ctx
+func (h *Hooks) RunCommit(ctx context.Context) {
+	h.run(ctx, func() []func(context.Context) { return h.commit })
+}
+
+func (h *Hooks) RunRollback(ctx context.Context) {
+	h.run(ctx, func() []func(context.Context) { return h.rollback })
+}
+
+func (h *Hooks) run(ctx context.Context, pick func() []func(context.Context)) {
+	h.mu.Lock()
+	fns := pick()
+	h.commit, h.rollback = nil, nil // 每组回调只触发一次
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		safeCall(ctx, fn)
+	}
+}
+
+func safeCall(ctx context.Context, fn func(ctx context.Context)) {
+	defer func() {
+		if p := recover(); p != nil {
+			metrics.TxHookPanicsTotal.Add(1)
+			slog.Default().Error("tx_hook_panic", slog.Any("panic", p))
+		}
+	}()
+	fn(ctx)
+}
@@ -1,7 +1,35 @@
 package tx
 
-import "context"
+import (
+	"context"
+	"database/sql"
+)
 
 type Transactor interface {
-	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// WithinTx 在事务里执行 fn；ctx 里已有事务时加入外层事务（用 SAVEPOINT 隔离本层的失败）
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...Option) error
+}
+
+// Options 只对最外层事务生效，嵌套调用沿用外层的设置
+type Options struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+}
+
+type Option func(*Options)
+
+func Isolation(level sql.IsolationLevel) Option {
+	return func(o *Options) { o.Isolation = level }
+}
+
+func ReadOnly() Option {
+	return func(o *Options) { o.ReadOnly = true }
+}
+
+func Apply(opts []Option) Options {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
 }
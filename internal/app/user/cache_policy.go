@@ -0,0 +1,39 @@
+package userapp
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// CachePolicy 控制 Get 的缓存行为
+type CachePolicy struct {
+	TTL         time.Duration // 正常缓存 TTL
+	TTLJitter   float64       // TTL 随机抖动比例（0.1 = 在 TTL 基础上随机加 0~10%），避免同批 key 同时过期
+	NegativeTTL time.Duration // 负缓存 TTL（ErrNotFound），0 表示不开启
+
+	// 概率提前刷新（XFetch）：剩余 TTL 越短、回源越慢，越可能由某个请求提前异步刷新
+	EarlyRefresh     bool
+	EarlyRefreshBeta float64 // >1 更积极，<1 更保守；0 按 1 处理
+}
+
+func (p CachePolicy) jitteredTTL() time.Duration {
+	if p.TTLJitter <= 0 || p.TTL <= 0 {
+		return p.TTL
+	}
+	return p.TTL + time.Duration(rand.Float64()*p.TTLJitter*float64(p.TTL))
+}
+
+// shouldRefreshEarly：XFetch 判定，delta 为最近一次回源耗时
+func (p CachePolicy) shouldRefreshEarly(remaining, delta time.Duration) bool {
+	if !p.EarlyRefresh || remaining <= 0 || delta <= 0 {
+		return false
+	}
+	beta := p.EarlyRefreshBeta
+	if beta <= 0 {
+		beta = 1
+	}
+	// -ln(rand) ∈ (0, +inf)，均值为 1
+	gap := float64(delta) * beta * -math.Log(1-rand.Float64())
+	return gap >= float64(remaining)
+}
@@ -0,0 +1,45 @@
+package userapp
+
+import (
+	"embed"
+	"encoding/json"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+)
+
+// schemas/<Type>.v<N>.json：已发布的版本只做兼容修改，不兼容时加新文件并登记新版本，
+// 同时提供从上一版本转换的 Upcast，consumer 和回放总是拿到最新版本
+//
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// EventSchemas：user 发布的全部事件及各版本 schema，按版本升序
+func EventSchemas() []eventschema.Definition {
+	return []eventschema.Definition{
+		// v1：没有 dataschema 的旧消息，最早只有 id/name/email，后来才带上 version/created_at
+		{Type: user.EventCreated, Version: 1, Schema: mustSchema("UserCreated.v1.json")},
+		{Type: user.EventCreated, Version: 2, Schema: mustSchema("UserCreated.v2.json"), Payload: user.CreatedEvent{}, Upcast: upcastUserCreatedV2},
+	}
+}
+
+// upcastUserCreatedV2：v2 要求 version；没有 version 的消息发布于加 version 列之前，
+// 那时的用户迁移后都是 1。created_at 无法补，v2 里仍是可选
+func upcastUserCreatedV2(prev json.RawMessage) (json.RawMessage, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(prev, &m); err != nil {
+		return nil, err
+	}
+	if _, ok := m["version"]; !ok {
+		m["version"] = json.RawMessage("1")
+	}
+	return json.Marshal(m)
+}
+
+func mustSchema(name string) []byte {
+	b, err := schemaFS.ReadFile("schemas/" + name)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -2,30 +2,42 @@ package userapp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
 	"github.com/hacker4257/go-ddd-template/internal/app/tx"
 	"github.com/hacker4257/go-ddd-template/internal/domain/event"
 	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/consistency"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/idgen"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
 	"github.com/hacker4257/go-ddd-template/internal/pkg/trace"
 )
 
 
 type Service struct {
-	repo  user.Repo
-	cache user.Cache
-	ttl   time.Duration
+	repo   user.Repo
+	cache  user.Cache
+	policy CachePolicy
 
 	tx     tx.Transactor
 	outbox event.Outbox
 	topic  string
+	ids    idgen.Generator // 用户和 outbox 消息的 ID 都在写库前生成
+
+	loads    singleflight.Group // 同一 id 的并发回源合并成一次
+	loadCost atomic.Int64       // 最近一次回源耗时（ns），给提前刷新用
 }
 
 
-func New(repo user.Repo, cache user.Cache, ttl time.Duration, tx tx.Transactor, outbox event.Outbox, topic string) *Service {
-	return &Service{repo: repo, cache: cache, ttl: ttl, tx: tx, outbox: outbox, topic: topic}
+func New(repo user.Repo, cache user.Cache, policy CachePolicy, tx tx.Transactor, outbox event.Outbox, topic string, ids idgen.Generator) *Service {
+	return &Service{repo: repo, cache: cache, policy: policy, tx: tx, outbox: outbox, topic: topic, ids: ids}
 }
 
 
@@ -41,61 +53,141 @@ func (s *Service) Create(ctx context.Context, cmd CreateUserCmd) (user.User, err
 		return user.User{}, user.ErrInvalidInput
 	}
 
-	// 先查一下（DB 唯一键也会兜底）
-	if _, err := s.repo.GetByEmail(ctx, email); err == nil {
+	// 先查一下（DB 唯一键也会兜底）；读主库，避免从库延迟漏掉刚注册的邮箱
+	if _, err := s.repo.GetByEmail(consistency.Strong(ctx), email); err == nil {
 		return user.User{}, user.ErrEmailExists
 	} else if err != nil && err != user.ErrNotFound {
 		return user.User{}, err
 	}
 	
+	userID, err := s.ids.NextID()
+	if err != nil {
+		return user.User{}, err
+	}
+	msgID, err := s.ids.NextID()
+	if err != nil {
+		return user.User{}, err
+	}
+
 	var created user.User
-	err := s.tx.WithinTx(ctx, func(tctx context.Context) error {
-		u, err := s.repo.Create(tctx, name, email)
+	err = s.tx.WithinTx(ctx, func(tctx context.Context) error {
+		u, err := s.repo.Create(tctx, userID, name, email)
 		if err != nil {
 			return err
 		}
 		created = u
 
+		// 只有提交成功才写缓存，回滚时不会留下脏数据
+		if s.cache != nil {
+			tx.AfterCommit(tctx, func(ctx context.Context) {
+				_ = s.cache.Set(ctx, u, s.policy.jitteredTTL())
+			})
+		}
+
 		rid := trace.RequestID(tctx)
 		return s.outbox.Add(tctx, event.OutboxMessage{
+			ID:    msgID,
 			Topic: s.topic,
 			Key: fmt.Sprintf("%d", u.ID),
-			Type: "UserCreated",
-			Payload: map[string]any{
-				"id": u.ID, "name": u.Name, "email": u.Email,
-			},
+			Type: user.EventCreated,
+			Payload: user.NewCreatedEvent(u),
 			Headers: map[string]string{
 				"request_id": rid,
 			},
+			Subject: strconv.FormatUint(u.ID, 10),
 		})
 	})
 	if err != nil {
 		return user.User{}, err
 	}
 
-	if s.cache != nil {
-		_ = s.cache.Set(ctx, created, s.ttl)
-	}
-
 	return created, nil
 }
 
 func (s *Service) Get(ctx context.Context, id uint64) (user.User, error) {
 	if s.cache != nil {
-		if u, ok, err := s.cache.Get(ctx, id); err == nil && ok {
-			return u, nil
+		u, hit, err := s.getCached(ctx, id)
+		if hit {
+			return u, err // err 为 ErrNotFound 表示命中负缓存
+		}
+	}
+
+	return s.load(ctx, id)
+}
+
+func (s *Service) getCached(ctx context.Context, id uint64) (user.User, bool, error) {
+	tc, ok := s.cache.(user.TTLCache)
+	if !ok || !s.policy.EarlyRefresh {
+		u, hit, err := s.cache.Get(ctx, id)
+		return s.cacheResult(u, hit, err)
+	}
+
+	u, remaining, hit, err := tc.GetWithTTL(ctx, id)
+	if hit && err == nil && s.policy.shouldRefreshEarly(remaining, time.Duration(s.loadCost.Load())) {
+		metrics.UserCacheEarlyRefreshTotal.Add(1)
+		// 异步刷新：当前请求直接返回旧值，刷新结果写回缓存
+		s.loads.DoChan(s.loadKey(id), func() (any, error) {
+			return s.loadFromRepo(context.WithoutCancel(ctx), id)
+		})
+	}
+	return s.cacheResult(u, hit, err)
+}
+
+func (s *Service) cacheResult(u user.User, hit bool, err error) (user.User, bool, error) {
+	switch {
+	case errors.Is(err, user.ErrNotFound):
+		metrics.UserCacheNegativeHitTotal.Add(1)
+		return user.User{}, true, user.ErrNotFound
+	case err != nil || !hit:
+		// 缓存异常按 miss 处理，不影响主流程
+		return user.User{}, false, nil
+	default:
+		return u, true, nil
+	}
+}
+
+// load：singleflight 合并并发回源；回源与调用方取消解耦，调用方只在自己的 ctx 上等待
+func (s *Service) load(ctx context.Context, id uint64) (user.User, error) {
+	ch := s.loads.DoChan(s.loadKey(id), func() (any, error) {
+		return s.loadFromRepo(context.WithoutCancel(ctx), id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return user.User{}, ctx.Err()
+	case res := <-ch:
+		if res.Shared {
+			metrics.UserLoadSharedTotal.Add(1)
+		}
+		if res.Err != nil {
+			return user.User{}, res.Err
 		}
+		return res.Val.(user.User), nil
 	}
+}
 
+func (s *Service) loadFromRepo(ctx context.Context, id uint64) (user.User, error) {
+	start := time.Now()
 	u, err := s.repo.GetByID(ctx, id)
+	s.loadCost.Store(int64(time.Since(start)))
+
+	if errors.Is(err, user.ErrNotFound) {
+		if s.cache != nil && s.policy.NegativeTTL > 0 {
+			_ = s.cache.SetNotFound(ctx, id, s.policy.NegativeTTL)
+		}
+		return user.User{}, err
+	}
 	if err != nil {
 		return user.User{}, err
 	}
 
 	if s.cache != nil {
-		_ = s.cache.Set(ctx, u, s.ttl) // 缓存失败不影响主流程
+		_ = s.cache.Set(ctx, u, s.policy.jitteredTTL()) // 缓存失败不影响主流程
 	}
 
 	return u, nil
 }
 
+func (s *Service) loadKey(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,247 @@
+package userapp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+)
+
+// fakeRepo：GetByID 计数，gate 不为 nil 时等它关闭再返回
+type fakeRepo struct {
+	mu    sync.Mutex
+	users map[uint64]user.User
+	loads int
+	gate  chan struct{}
+}
+
+func (r *fakeRepo) Create(context.Context, uint64, string, string) (user.User, error) {
+	return user.User{}, errors.New("not implemented")
+}
+
+func (r *fakeRepo) GetByEmail(context.Context, string) (user.User, error) {
+	return user.User{}, user.ErrNotFound
+}
+
+func (r *fakeRepo) GetByID(_ context.Context, id uint64) (user.User, error) {
+	r.mu.Lock()
+	r.loads++
+	gate := r.gate
+	r.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return user.User{}, user.ErrNotFound
+	}
+	return u, nil
+}
+
+func (r *fakeRepo) loadCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loads
+}
+
+// fakeCache：内存缓存，记录写入的 TTL
+type fakeCache struct {
+	mu       sync.Mutex
+	users    map[uint64]user.User
+	notFound map[uint64]time.Duration
+	ttls     []time.Duration
+	gets     int
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{users: make(map[uint64]user.User), notFound: make(map[uint64]time.Duration)}
+}
+
+func (c *fakeCache) Get(_ context.Context, id uint64) (user.User, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	if _, ok := c.notFound[id]; ok {
+		return user.User{}, true, user.ErrNotFound
+	}
+	u, ok := c.users[id]
+	return u, ok, nil
+}
+
+func (c *fakeCache) Set(_ context.Context, u user.User, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users[u.ID] = u
+	c.ttls = append(c.ttls, ttl)
+	return nil
+}
+
+func (c *fakeCache) SetNotFound(_ context.Context, id uint64, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notFound[id] = ttl
+	return nil
+}
+
+func (c *fakeCache) Del(_ context.Context, id uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.users, id)
+	delete(c.notFound, id)
+	return nil
+}
+
+func (c *fakeCache) Evict(ctx context.Context, id uint64, _ uint64) error { return c.Del(ctx, id) }
+
+func (c *fakeCache) getCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gets
+}
+
+func newTestService(repo user.Repo, cache user.Cache, policy CachePolicy) *Service {
+	return New(repo, cache, policy, nil, nil, "user.events", nil)
+}
+
+func TestGetLoadsOnceUnderConcurrency(t *testing.T) {
+	const callers = 20
+	repo := &fakeRepo{users: map[uint64]user.User{1: {ID: 1, Name: "a"}}, gate: make(chan struct{})}
+	cache := newFakeCache()
+	s := newTestService(repo, cache, CachePolicy{TTL: time.Minute})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, err := s.Get(context.Background(), 1)
+			if err == nil && u.Name != "a" {
+				err = errors.New("wrong user " + u.Name)
+			}
+			errs <- err
+		}()
+	}
+
+	// 所有调用方都 miss 了缓存、挂在同一次回源上之后再放行
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.getCount() < callers {
+		if time.Now().After(deadline) {
+			t.Fatal("callers did not reach the cache")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(repo.gate)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := repo.loadCount(); got != 1 {
+		t.Errorf("repo loads = %d, want 1", got)
+	}
+	if _, ok := cache.users[1]; !ok {
+		t.Error("loaded user not cached")
+	}
+}
+
+// 调用方取消只影响自己，回源照常完成并写缓存
+func TestGetCallerCancelDoesNotAbortLoad(t *testing.T) {
+	repo := &fakeRepo{users: map[uint64]user.User{1: {ID: 1, Name: "a"}}, gate: make(chan struct{})}
+	cache := newFakeCache()
+	s := newTestService(repo, cache, CachePolicy{TTL: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Get(ctx, 1)
+		done <- err
+	}()
+	for repo.loadCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	close(repo.gate)
+	if u, err := s.Get(context.Background(), 1); err != nil || u.Name != "a" {
+		t.Fatalf("Get = %+v, %v", u, err)
+	}
+	if got := repo.loadCount(); got != 1 {
+		t.Errorf("repo loads = %d, want the canceled load to finish and be reused", got)
+	}
+}
+
+func TestGetReturnsCachedNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{}
+	cache := newFakeCache()
+	s := newTestService(repo, cache, CachePolicy{TTL: time.Minute, NegativeTTL: 30 * time.Second})
+
+	for range 3 {
+		if _, err := s.Get(ctx, 7); !errors.Is(err, user.ErrNotFound) {
+			t.Fatalf("err = %v, want ErrNotFound", err)
+		}
+	}
+	if got := repo.loadCount(); got != 1 {
+		t.Errorf("repo loads = %d, want 1", got)
+	}
+	if ttl := cache.notFound[7]; ttl != 30*time.Second {
+		t.Errorf("negative TTL = %v, want 30s", ttl)
+	}
+
+	// 不开负缓存：每次都回源
+	repo = &fakeRepo{}
+	s = newTestService(repo, newFakeCache(), CachePolicy{TTL: time.Minute})
+	for range 2 {
+		if _, err := s.Get(ctx, 7); !errors.Is(err, user.ErrNotFound) {
+			t.Fatalf("err = %v, want ErrNotFound", err)
+		}
+	}
+	if got := repo.loadCount(); got != 2 {
+		t.Errorf("repo loads = %d without negative caching, want 2", got)
+	}
+}
+
+func TestJitteredTTL(t *testing.T) {
+	p := CachePolicy{TTL: 100 * time.Second, TTLJitter: 0.1}
+	seen := make(map[time.Duration]bool)
+	for range 1000 {
+		ttl := p.jitteredTTL()
+		if ttl < p.TTL || ttl > 110*time.Second {
+			t.Fatalf("ttl = %v, want within [100s, 110s]", ttl)
+		}
+		seen[ttl] = true
+	}
+	if len(seen) < 10 {
+		t.Errorf("only %d distinct TTLs, want jitter", len(seen))
+	}
+
+	for _, p := range []CachePolicy{{TTL: time.Minute}, {TTL: time.Minute, TTLJitter: -1}} {
+		if got := p.jitteredTTL(); got != time.Minute {
+			t.Errorf("%+v: ttl = %v, want no jitter", p, got)
+		}
+	}
+
+	// 回源写缓存用的是抖动后的 TTL
+	repo := &fakeRepo{users: map[uint64]user.User{1: {ID: 1}}}
+	cache := newFakeCache()
+	if _, err := newTestService(repo, cache, p).Get(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.ttls) != 1 || cache.ttls[0] < p.TTL || cache.ttls[0] > 110*time.Second {
+		t.Errorf("cached with TTLs %v, want one jittered TTL", cache.ttls)
+	}
+}
@@ -3,9 +3,10 @@ package audit
 import "time"
 
 type Log struct {
-	ID        uint64
-	EventType string
-	EventKey  string
-	Payload   []byte
-	CreatedAt time.Time
+	ID         uint64
+	EventType  string
+	EventKey   string
+	DataSchema string // payload 的 schema 版本（CloudEvents dataschema），空表示 v1
+	Payload    []byte
+	CreatedAt  time.Time
 }
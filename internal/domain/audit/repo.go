@@ -3,5 +3,7 @@ package audit
 import "context"
 
 type Repo interface {
-	Insert(ctx context.Context, eventType, eventKey string, payload []byte) error
+	Insert(ctx context.Context, eventType, eventKey, dataSchema string, payload []byte) error
+	// List 按 id 升序返回 id > afterID 的记录，eventType 为空时不过滤类型
+	List(ctx context.Context, afterID uint64, eventType string, limit int) ([]Log, error)
 }
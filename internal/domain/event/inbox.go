@@ -0,0 +1,17 @@
+package event
+
+import (
+	"context"
+	"time"
+)
+
+// Inbox：消费端去重表，必须和处理的副作用写在同一个事务里，二者要么都生效要么都不生效
+type Inbox interface {
+	// Record 登记 consumer 处理过 eventID；返回 false 表示已经处理过，应跳过
+	Record(ctx context.Context, consumer, eventID string) (bool, error)
+}
+
+// InboxRetention：保留期之外的去重记录分批清理；保留期要长于消息可能被重投的时间窗
+type InboxRetention interface {
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+}
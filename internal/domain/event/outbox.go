@@ -1,15 +1,79 @@
 package event
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLeaseLost：行已不在自己名下（租约过期后被别人领走、或已被标记），这次标记没有生效
+var ErrLeaseLost = errors.New("outbox: lease lost")
 
 type OutboxMessage struct {
+	ID      uint64            `json:"id"` // 应用侧生成；0 表示交给数据库分配（MySQL 不支持，必须传）
 	Topic   string            `json:"topic"`
 	Key     string            `json:"key"`
 	Type    string            `json:"type"`
-	Payload map[string]any    `json:"payload"`
+	Payload any               `json:"payload"` // 事件类型对应的结构体，按 JSON 编码后存储
 	Headers map[string]string `json:"headers"`
+
+	// CloudEvents 属性：subject 是事件针对的对象（比如 user id），dataschema 是 payload 的 schema URI
+	// （为空时由 schema 校验补上最新版本）
+	Subject    string `json:"subject,omitempty"`
+	DataSchema string `json:"dataschema,omitempty"`
 }
 
 type Outbox interface {
 	Add(ctx context.Context, m OutboxMessage) error
 }
+
+// OutboxRecord：outbox 表里待投递的一行（payload / headers 为 JSON 原文）
+type OutboxRecord struct {
+	ID      uint64
+	Topic   string
+	MsgKey  string
+	Type    string
+	Payload []byte
+	Headers []byte
+
+	Attempts  int // 此前失败的次数
+	CreatedAt time.Time
+
+	SentAt time.Time // 仅 ListSentBefore 填充（归档用）
+}
+
+// OutboxSource：给 worker 的 dispatcher 用，领取并标记已发送 / 失败
+// 多个 dispatcher 副本靠租约分摊：同一行在租约期内只会被一个 owner 领到
+//
+// 行状态：pending → sent，或失败若干次后进入终态 dead（需人工处理）
+type OutboxSource interface {
+	// Claim 按 id 升序领取最多 limit 条可投递的 pending 消息，租约为 lease；
+	// 租约过期（持有者崩溃）的行可被任何 owner 重新领取，owner 自己的未过期租约可以续领。
+	// 同一 msg_key 下有更早的行在退避中（或被别人持有）时，后面的行不会被领取，保证按 key 有序
+	Claim(ctx context.Context, owner string, lease time.Duration, limit int) ([]OutboxRecord, error)
+	// MarkSent / MarkFailed 只改仍由 owner 持有的 pending 行（owner 为空表示没领过租约的行，给 binlog 中继用），
+	// 有行不在 owner 名下时返回 ErrLeaseLost，其余行照常标记
+	MarkSent(ctx context.Context, owner string, ids []uint64) error
+	// MarkFailed 记一次失败：attempts+1、记录 lastErr，retryIn 之后才能再被领取；dead 为 true 时进入终态
+	MarkFailed(ctx context.Context, owner string, id uint64, lastErr string, retryIn time.Duration, dead bool) error
+}
+
+// OutboxRetention：给 worker 的清理任务用，按批删除早已发送的行
+type OutboxRetention interface {
+	// ListSentBefore 按 id 升序返回 sent_at 早于 before 的已发送行
+	ListSentBefore(ctx context.Context, before time.Time, limit int) ([]OutboxRecord, error)
+	Delete(ctx context.Context, ids []uint64) (int64, error)
+	// Stats 表行数和占用字节（可以是估算值）
+	Stats(ctx context.Context) (OutboxStats, error)
+}
+
+type OutboxStats struct {
+	Rows  int64
+	Bytes int64
+	Dead  int64 // dead 状态的行数（告警用）
+}
+
+// OutboxNotifier：outbox 有新行提交后提醒 dispatcher 立即领取；尽力而为，丢了由轮询兜底
+type OutboxNotifier interface {
+	Notify(ctx context.Context)
+}
@@ -6,7 +6,14 @@ import (
 )
 
 type Cache interface {
-	Get(ctx context.Context, id uint64) (User, bool, error) // bool = hit?
-	Set(ctx context.Context, u User, ttl time.Duration) error
+	Get(ctx context.Context, id uint64) (User, bool, error) // bool = hit?；命中负缓存时返回 ErrNotFound
+	Set(ctx context.Context, u User, ttl time.Duration) error             // 已缓存更新的版本时返回 ErrStaleVersion
+	SetNotFound(ctx context.Context, id uint64, ttl time.Duration) error // 负缓存：记录该 id 不存在
 	Del(ctx context.Context, id uint64) error
+	Evict(ctx context.Context, id uint64, version uint64) error // 删除并记住版本，之后该版本及更旧的 Set 都会被拒绝
+}
+
+// TTLCache 可选能力：命中时同时返回剩余 TTL，用于热点 key 的概率提前刷新
+type TTLCache interface {
+	GetWithTTL(ctx context.Context, id uint64) (User, time.Duration, bool, error)
 }
@@ -6,5 +6,6 @@ type User struct {
 	ID        uint64
 	Name      string
 	Email     string
+	Version   uint64 // 每次变更 +1，用来判断缓存/事件的新旧
 	CreatedAt time.Time
 }
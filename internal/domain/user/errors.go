@@ -6,4 +6,5 @@ var (
 	ErrNotFound      = errors.New("user not found")
 	ErrEmailExists   = errors.New("email already exists")
 	ErrInvalidInput  = errors.New("invalid input")
+	ErrStaleVersion  = errors.New("stale user version")
 )
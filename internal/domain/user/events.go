@@ -0,0 +1,19 @@
+package user
+
+import "time"
+
+// 事件类型（CloudEvents type）
+const EventCreated = "UserCreated"
+
+// CreatedEvent：UserCreated 的 payload（当前版本）
+type CreatedEvent struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Version   uint64    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewCreatedEvent(u User) CreatedEvent {
+	return CreatedEvent{ID: u.ID, Name: u.Name, Email: u.Email, Version: u.Version, CreatedAt: u.CreatedAt}
+}
@@ -3,7 +3,7 @@ package user
 import "context"
 
 type Repo interface {
-	Create(ctx context.Context, name, email string) (User, error)
+	Create(ctx context.Context, id uint64, name, email string) (User, error) // id 由应用侧生成
 	GetByID(ctx context.Context, id uint64) (User, error)
 	GetByEmail(ctx context.Context, email string) (User, error)
 }
@@ -0,0 +1,117 @@
+package local
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+	"time"
+)
+
+// LRU：有界、带过期时间的进程内缓存（并发安全）。
+// 淘汰按 LRU，准入按 TinyLFU：满了以后新 key 的访问频率要高于将被淘汰的那个才会放进来，
+// 一次性的冷 key 不会把热点挤出去
+type LRU[K comparable, V any] struct {
+	mu     sync.Mutex
+	size   int
+	ll     *list.List
+	items  map[K]*list.Element
+	hash   func(K) uint64
+	sketch *cmSketch
+	now    func() time.Time
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	val       V
+	expiresAt time.Time
+}
+
+func NewLRU[K comparable, V any](size int) *LRU[K, V] {
+	if size <= 0 {
+		size = 1
+	}
+	seed := maphash.MakeSeed()
+	return &LRU[K, V]{
+		size:   size,
+		ll:     list.New(),
+		items:  make(map[K]*list.Element, size),
+		hash:   func(k K) uint64 { return maphash.Comparable(seed, k) },
+		sketch: newCMSketch(size),
+		now:    time.Now,
+	}
+}
+
+// Get 命中与否都计一次访问频率
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sketch.Increment(c.hash(key))
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*lruEntry[K, V])
+	if c.now().After(e.expiresAt) {
+		c.removeElement(el)
+		var zero V
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return e.val, true
+}
+
+// Set 更新已有的 key 总是生效；新 key 在满了的时候可能不被准入
+func (c *LRU[K, V]) Set(key K, val V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exp := c.now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry[K, V])
+		e.val, e.expiresAt = val, exp
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	if c.ll.Len() >= c.size {
+		victim := c.ll.Back()
+		// 过期的直接让位；否则比较频率，打平时留下已有的
+		if ve := victim.Value.(*lruEntry[K, V]); !c.now().After(ve.expiresAt) &&
+			c.sketch.Estimate(c.hash(key)) <= c.sketch.Estimate(c.hash(ve.key)) {
+			return
+		}
+		c.removeElement(victim)
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, val: val, expiresAt: exp})
+}
+
+func (c *LRU[K, V]) Del(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Purge 清空全部条目（比如失效通道断线重连后，无法确定漏掉了哪些通知）
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[K]*list.Element, c.size)
+}
+
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry[K, V]).key)
+}
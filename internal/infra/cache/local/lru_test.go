@@ -0,0 +1,153 @@
+package local
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestLRU：固定时钟；每个 key 按出现顺序编号当哈希，sketch 里没有碰撞，频率比较是确定的
+func newTestLRU(size int) (*LRU[string, int], *fakeClock) {
+	clk := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewLRU[string, int](size)
+	c.now = clk.now
+	ids := make(map[string]uint64)
+	c.hash = func(k string) uint64 {
+		if _, ok := ids[k]; !ok {
+			ids[k] = uint64(len(ids))
+		}
+		return ids[k]
+	}
+	return c, clk
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestLRU(2)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	c.Get("a") // a 变成最近使用，b 排到队尾
+
+	// c 先被访问过两次（回源前的 miss），频率高于队尾的 b，准入并淘汰 b
+	c.Get("c")
+	c.Get("c")
+	c.Set("c", 3, time.Minute)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b still cached, want evicted")
+	}
+	for k, want := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.Get(k); !ok || v != want {
+			t.Errorf("Get(%q) = %d, %v, want %d", k, v, ok, want)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	c, clk := newTestLRU(2)
+	c.Set("a", 1, time.Second)
+	c.Set("b", 2, time.Minute)
+
+	clk.advance(2 * time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a returned after its TTL")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want expired entry removed", c.Len())
+	}
+
+	// 更新已有的 key 会顺带续期
+	c.Set("b", 3, time.Minute)
+	clk.advance(50 * time.Second)
+	if v, ok := c.Get("b"); !ok || v != 3 {
+		t.Errorf("Get(b) = %d, %v, want 3", v, ok)
+	}
+}
+
+func TestLRUExpiredVictimMakesRoom(t *testing.T) {
+	c, clk := newTestLRU(1)
+	c.Get("hot")
+	c.Get("hot")
+	c.Set("hot", 1, time.Second)
+	clk.advance(2 * time.Second)
+
+	// 队尾已经过期：频率再低的新 key 也能放进来
+	c.Set("cold", 2, time.Minute)
+	if v, ok := c.Get("cold"); !ok || v != 2 {
+		t.Errorf("Get(cold) = %d, %v, want 2", v, ok)
+	}
+}
+
+func TestLRUAdmissionKeepsHotKeys(t *testing.T) {
+	const size = 10
+	c, _ := newTestLRU(size)
+	for i := range size {
+		k := fmt.Sprintf("hot%d", i)
+		for range 3 {
+			c.Get(k)
+		}
+		c.Set(k, i, time.Minute)
+	}
+
+	// 热点照常被访问，同时夹着一大波只访问一次的 key（比如扫描）：
+	// 纯 LRU 下每个热点都会在两次访问之间被挤到队尾淘汰，准入之后一个都不该丢
+	for i := range 50 {
+		if _, ok := c.Get(fmt.Sprintf("hot%d", i%size)); !ok {
+			t.Fatalf("hot%d evicted after %d scan keys", i%size, i)
+		}
+		k := fmt.Sprintf("scan%d", i)
+		c.Get(k)
+		c.Set(k, i, time.Minute)
+	}
+
+	for i := range size {
+		k := fmt.Sprintf("hot%d", i)
+		if v, ok := c.Get(k); !ok || v != i {
+			t.Errorf("Get(%q) = %d, %v, want %d", k, v, ok, i)
+		}
+	}
+
+	// 已有的 key 不受准入限制，更新总是生效
+	c.Set("hot0", 42, time.Minute)
+	if v, _ := c.Get("hot0"); v != 42 {
+		t.Errorf("Get(hot0) = %d after update, want 42", v)
+	}
+}
+
+func TestLRUDelAndPurge(t *testing.T) {
+	c, _ := newTestLRU(4)
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+
+	c.Del("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("a cached after Del")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after Purge, want 0", c.Len())
+	}
+}
+
+func TestSketchDecays(t *testing.T) {
+	s := newCMSketch(1)
+	for range 5 {
+		s.Increment(1)
+	}
+	if got := s.Estimate(1); got != 5 {
+		t.Fatalf("Estimate = %d, want 5", got)
+	}
+	for range s.sampleSize {
+		s.Increment(2)
+	}
+	if got := s.Estimate(1); got >= 5 {
+		t.Errorf("Estimate = %d after a sample period, want it halved", got)
+	}
+}
@@ -0,0 +1,58 @@
+package local
+
+// cmSketch：TinyLFU 用的 count-min sketch，估计 key 最近的访问频率。
+// 4 行计数器（每个饱和到 15），累计 sampleSize 次访问后全部减半，旧的热度逐渐衰减
+type cmSketch struct {
+	rows       [4][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+const sketchMaxCount = 15
+
+func newCMSketch(size int) *cmSketch {
+	width := 16
+	for width < 4*size { // 宽一些，减少碰撞带来的高估
+		width <<= 1
+	}
+	s := &cmSketch{mask: uint64(width - 1), sampleSize: 10 * max(size, 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index：双重哈希，每行取不同的位置
+func (s *cmSketch) index(h uint64, row int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32|1
+	return (h1 + uint64(row)*h2) & s.mask
+}
+
+func (s *cmSketch) Increment(h uint64) {
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < sketchMaxCount {
+			*c++
+		}
+	}
+	if s.additions++; s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) Estimate(h uint64) uint8 {
+	est := uint8(sketchMaxCount)
+	for i := range s.rows {
+		est = min(est, s.rows[i][s.index(h, i)])
+	}
+	return est
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -2,23 +2,134 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"time"
 
 	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/hacker4257/go-ddd-template/internal/pkg/config"
+)
+
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
 )
 
 type Config struct {
-	Addr     string
+	Mode string // standalone（默认）/ sentinel / cluster
+
+	Addr  string   // standalone
+	Addrs []string // sentinel：哨兵地址；cluster：种子节点
+
+	MasterName       string // sentinel
+	SentinelPassword string // sentinel
+
+	Username string
 	Password string
-	DB       int
+	DB       int // cluster 只支持 0
+
+	TLS                   bool
+	TLSInsecureSkipVerify bool
+
+	PoolSize     int
+	MinIdleConns int
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+}
+
+// FromConfig：server / worker 共用的配置映射
+func FromConfig(c config.RedisConfig) Config {
+	return Config{
+		Mode:                  c.Mode,
+		Addr:                  c.Addr,
+		Addrs:                 c.Addrs,
+		MasterName:            c.MasterName,
+		SentinelPassword:      c.SentinelPassword,
+		Username:              c.Username,
+		Password:              c.Password,
+		DB:                    c.DB,
+		TLS:                   c.TLS,
+		TLSInsecureSkipVerify: c.TLSInsecureSkipVerify,
+		PoolSize:              c.PoolSize,
+		MinIdleConns:          c.MinIdleConns,
+		DialTimeout:           c.DialTimeout,
+		ReadTimeout:           c.ReadTimeout,
+		WriteTimeout:          c.WriteTimeout,
+		PoolTimeout:           c.PoolTimeout,
+	}
 }
 
-func NewClient(cfg Config) (*goredis.Client, error) {
-	rdb := goredis.NewClient(&goredis.Options{
-		Addr:     cfg.Addr,
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
+func NewClient(cfg Config) (goredis.UniversalClient, error) {
+	var tlsCfg *tls.Config
+	if cfg.TLS {
+		tlsCfg = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify, // 仅测试环境显式打开
+		}
+	}
+
+	var rdb goredis.UniversalClient
+	switch cfg.Mode {
+	case "", ModeStandalone:
+		rdb = goredis.NewClient(&goredis.Options{
+			Addr:         cfg.Addr,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			TLSConfig:    tlsCfg,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolTimeout:  cfg.PoolTimeout,
+		})
+	case ModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel: master_name and addrs are required")
+		}
+		rdb = goredis.NewFailoverClient(&goredis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			TLSConfig:        tlsCfg,
+			PoolSize:         cfg.PoolSize,
+			MinIdleConns:     cfg.MinIdleConns,
+			DialTimeout:      cfg.DialTimeout,
+			ReadTimeout:      cfg.ReadTimeout,
+			WriteTimeout:     cfg.WriteTimeout,
+			PoolTimeout:      cfg.PoolTimeout,
+		})
+	case ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("redis cluster: addrs are required")
+		}
+		if cfg.DB != 0 {
+			return nil, fmt.Errorf("redis cluster: db must be 0, got %d", cfg.DB)
+		}
+		rdb = goredis.NewClusterClient(&goredis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			TLSConfig:    tlsCfg,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			PoolTimeout:  cfg.PoolTimeout,
+		})
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
 
 	if err := rdb.Ping(context.Background()).Err(); err != nil {
 		_ = rdb.Close()
@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// InvalidationBus：通过 Redis pub/sub 广播缓存失效，让每个副本清掉自己的进程内缓存
+type InvalidationBus struct {
+	rdb     goredis.UniversalClient
+	channel string
+}
+
+func NewInvalidationBus(rdb goredis.UniversalClient, channel string) *InvalidationBus {
+	return &InvalidationBus{rdb: rdb, channel: channel}
+}
+
+func (b *InvalidationBus) Publish(ctx context.Context, id uint64) error {
+	return b.rdb.Publish(ctx, b.channel, strconv.FormatUint(id, 10)).Err()
+}
+
+// Subscribe 阻塞直到 ctx 结束：
+// evict 处理单个 id；reset 在（重新）订阅成功时调用，断线期间可能漏掉通知，只能整体清空
+func (b *InvalidationBus) Subscribe(ctx context.Context, log *slog.Logger, evict func(id uint64), reset func()) {
+	ps := b.rdb.Subscribe(ctx, b.channel)
+	defer ps.Close()
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error("cache_invalidation_receive_error", slog.Any("err", err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second): // 避免 Redis 不可用时空转
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *goredis.Subscription:
+			if m.Kind == "subscribe" {
+				reset()
+			}
+		case *goredis.Message:
+			id, err := strconv.ParseUint(m.Payload, 10, 64)
+			if err != nil {
+				log.Warn("cache_invalidation_bad_payload", slog.String("payload", m.Payload))
+				continue
+			}
+			evict(id)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/wakeup"
+)
+
+// OutboxNudge：跨进程唤醒 outbox dispatcher。server 提交后 PUBLISH，worker 订阅后立即领取
+// 只是加速手段：消息丢了、Redis 不可用时 dispatcher 仍按轮询间隔兜底
+type OutboxNudge struct {
+	rdb     goredis.UniversalClient
+	channel string
+	pending *wakeup.Signal
+}
+
+func NewOutboxNudge(rdb goredis.UniversalClient, channel string) *OutboxNudge {
+	return &OutboxNudge{rdb: rdb, channel: channel, pending: wakeup.NewSignal()}
+}
+
+// Notify 在请求路径上调用，不等 Redis：只打个标记，由 Run 合并后发送
+func (n *OutboxNudge) Notify(ctx context.Context) {
+	n.pending.Notify(ctx)
+}
+
+// Run 阻塞直到 ctx 结束；突发写入时多次 Notify 合并成一条 PUBLISH
+func (n *OutboxNudge) Run(ctx context.Context, log *slog.Logger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.pending.C():
+		}
+
+		pctx, cancel := context.WithTimeout(ctx, time.Second)
+		err := n.rdb.Publish(pctx, n.channel, "").Err()
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			log.Warn("outbox_nudge_publish_error", slog.Any("err", err))
+			continue
+		}
+		metrics.OutboxNudgesTotal.Add(1)
+	}
+}
+
+// Subscribe 阻塞直到 ctx 结束，每收到一条（以及每次重新订阅成功时）调用 wake；
+// 断线期间可能漏掉通知，重连后先唤醒一次补上
+func (n *OutboxNudge) Subscribe(ctx context.Context, log *slog.Logger, wake func()) {
+	ps := n.rdb.Subscribe(ctx, n.channel)
+	defer ps.Close()
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error("outbox_nudge_receive_error", slog.Any("err", err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second): // 避免 Redis 不可用时空转
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *goredis.Subscription:
+			if m.Kind == "subscribe" {
+				wake()
+			}
+		case *goredis.Message:
+			wake()
+		}
+	}
+}
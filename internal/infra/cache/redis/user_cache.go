@@ -2,25 +2,68 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
 
 	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
 )
 
+// 负缓存占位值（不带信封 magic，不会和正常数据冲突）
+const notFoundMarker = "!nf"
+
+// 版本栅栏至少保留这么久：期间任何更旧版本的写入都会被拒绝
+const versionFenceTTL = time.Hour
+
+// KEYS[1]=数据 key，KEYS[2]=版本 key；ARGV[1]=值，ARGV[2]=版本，ARGV[3]=数据 TTL(ms)，ARGV[4]=版本 TTL(ms)
+var setIfNewerScript = goredis.NewScript(`
+local cur = redis.call('GET', KEYS[2])
+if cur and tonumber(cur) > tonumber(ARGV[2]) then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[4])
+return 1
+`)
+
+// 只有没见过任何版本时才允许写负缓存（否则可能是从库延迟读到的“不存在”）
+var setNotFoundScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// KEYS 同上；ARGV[1]=栅栏版本，ARGV[2]=版本 TTL(ms)
+var evictScript = goredis.NewScript(`
+local cur = redis.call('GET', KEYS[2])
+if not cur or tonumber(cur) < tonumber(ARGV[1]) then
+  redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[2])
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
 type UserCache struct {
-	rdb *goredis.Client
+	rdb   goredis.UniversalClient
+	codec *UserCodec
 }
 
-func NewUserCache(rdb *goredis.Client) *UserCache {
-	return &UserCache{rdb: rdb}
+func NewUserCache(rdb goredis.UniversalClient, codec *UserCodec) *UserCache {
+	return &UserCache{rdb: rdb, codec: codec}
 }
 
+// key 用 hash tag，保证数据和版本落在同一个 slot（Lua 多 key 需要）
 func (c *UserCache) key(id uint64) string {
-	return fmt.Sprintf("user:%d", id)
+	return fmt.Sprintf("user:{%d}", id)
+}
+
+func (c *UserCache) versionKey(id uint64) string {
+	return fmt.Sprintf("user:{%d}:ver", id)
 }
 
 func (c *UserCache) Get(ctx context.Context, id uint64) (user.User, bool, error) {
@@ -31,10 +74,40 @@ func (c *UserCache) Get(ctx context.Context, id uint64) (user.User, bool, error)
 	if err != nil {
 		return user.User{}, false, err
 	}
+	return c.decode(ctx, id, val)
+}
+
+// GetWithTTL：GET + PTTL 走一个 pipeline
+func (c *UserCache) GetWithTTL(ctx context.Context, id uint64) (user.User, time.Duration, bool, error) {
+	var (
+		get *goredis.StringCmd
+		ttl *goredis.DurationCmd
+	)
+	_, err := c.rdb.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		get = p.Get(ctx, c.key(id))
+		ttl = p.PTTL(ctx, c.key(id))
+		return nil
+	})
+	if err == goredis.Nil {
+		return user.User{}, 0, false, nil
+	}
+	if err != nil {
+		return user.User{}, 0, false, err
+	}
+
+	u, ok, err := c.decode(ctx, id, get.Val())
+	return u, ttl.Val(), ok, err
+}
 
-	var u user.User
-	if err := json.Unmarshal([]byte(val), &u); err != nil {
-		// 解析失败：当作 miss（也可以顺手删掉坏缓存）
+func (c *UserCache) decode(ctx context.Context, id uint64, val string) (user.User, bool, error) {
+	if val == notFoundMarker {
+		return user.User{}, true, user.ErrNotFound
+	}
+
+	u, err := c.codec.Decode([]byte(val))
+	if err != nil {
+		// 未知版本/解析失败：当作 miss 并删掉（滚动发布期间新旧格式共存也安全）
+		metrics.UserCacheDecodeErrorTotal.Add(1)
 		_ = c.rdb.Del(ctx, c.key(id)).Err()
 		return user.User{}, false, nil
 	}
@@ -43,13 +116,46 @@ func (c *UserCache) Get(ctx context.Context, id uint64) (user.User, bool, error)
 }
 
 func (c *UserCache) Set(ctx context.Context, u user.User, ttl time.Duration) error {
-	b, err := json.Marshal(u)
+	b, err := c.codec.Encode(u)
+	if err != nil {
+		return err
+	}
+
+	ok, err := setIfNewerScript.Run(ctx, c.rdb,
+		[]string{c.key(u.ID), c.versionKey(u.ID)},
+		b, strconv.FormatUint(u.Version, 10), ttl.Milliseconds(), max(ttl, versionFenceTTL).Milliseconds(),
+	).Int()
 	if err != nil {
 		return err
 	}
-	return c.rdb.Set(ctx, c.key(u.ID), b, ttl).Err()
+	if ok == 0 {
+		return user.ErrStaleVersion
+	}
+	return nil
+}
+
+func (c *UserCache) SetNotFound(ctx context.Context, id uint64, ttl time.Duration) error {
+	ok, err := setNotFoundScript.Run(ctx, c.rdb,
+		[]string{c.key(id), c.versionKey(id)},
+		notFoundMarker, ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return user.ErrStaleVersion
+	}
+	return nil
 }
 
 func (c *UserCache) Del(ctx context.Context, id uint64) error {
 	return c.rdb.Del(ctx, c.key(id)).Err()
 }
+
+// Evict 把栅栏设在 version+1：被删除的版本本身（从库延迟读到的、还在路上的回源）也写不回来
+func (c *UserCache) Evict(ctx context.Context, id uint64, version uint64) error {
+	return evictScript.Run(ctx, c.rdb,
+		[]string{c.key(id), c.versionKey(id)},
+		strconv.FormatUint(version+1, 10), versionFenceTTL.Milliseconds(),
+	).Err()
+}
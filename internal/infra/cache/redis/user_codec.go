@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+)
+
+// 缓存信封：| magic | format version | codec | flags | body... |
+// 升级 user 缓存结构时 bump userFormatVersion，旧副本读到新格式（或反过来）都按 miss 处理
+const (
+	envelopeMagic     byte = 0xCA
+	userFormatVersion byte = 1
+
+	codecJSON    byte = 1
+	codecMsgpack byte = 2
+
+	flagS2 byte = 1 << 0
+
+	envelopeHeaderLen = 4
+)
+
+var ErrUnknownFormat = errors.New("unknown cache format")
+
+// cachedUserV1：缓存里的结构，和 domain 实体解耦，字段变化时配合 userFormatVersion 一起改
+type cachedUserV1 struct {
+	ID        uint64    `json:"id" msgpack:"id"`
+	Name      string    `json:"name" msgpack:"name"`
+	Email     string    `json:"email" msgpack:"email"`
+	Version   uint64    `json:"version" msgpack:"version"`
+	CreatedAt time.Time `json:"created_at" msgpack:"created_at"`
+}
+
+// UserCodec：写入时用配置的 codec；读取时按信封里的 codec 解码，切换 codec 不需要清缓存
+type UserCodec struct {
+	codec        byte
+	compressOver int // body 超过这个字节数才压缩，<=0 不压缩
+}
+
+func NewUserCodec(name string, compressOver int) (*UserCodec, error) {
+	switch name {
+	case "", "msgpack":
+		return &UserCodec{codec: codecMsgpack, compressOver: compressOver}, nil
+	case "json":
+		return &UserCodec{codec: codecJSON, compressOver: compressOver}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache codec %q", name)
+	}
+}
+
+func (c *UserCodec) Encode(u user.User) ([]byte, error) {
+	v := cachedUserV1{ID: u.ID, Name: u.Name, Email: u.Email, Version: u.Version, CreatedAt: u.CreatedAt}
+
+	var (
+		body []byte
+		err  error
+	)
+	switch c.codec {
+	case codecJSON:
+		body, err = json.Marshal(v)
+	default:
+		body, err = msgpack.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if c.compressOver > 0 && len(body) > c.compressOver {
+		body = s2.Encode(nil, body)
+		flags |= flagS2
+	}
+
+	out := make([]byte, 0, envelopeHeaderLen+len(body))
+	out = append(out, envelopeMagic, userFormatVersion, c.codec, flags)
+	return append(out, body...), nil
+}
+
+func (c *UserCodec) Decode(b []byte) (user.User, error) {
+	if len(b) < envelopeHeaderLen || b[0] != envelopeMagic || b[1] != userFormatVersion {
+		return user.User{}, ErrUnknownFormat
+	}
+	codec, flags, body := b[2], b[3], b[envelopeHeaderLen:]
+
+	if flags&flagS2 != 0 {
+		var err error
+		if body, err = s2.Decode(nil, body); err != nil {
+			return user.User{}, fmt.Errorf("cache decompress: %w", err)
+		}
+	}
+
+	var (
+		v   cachedUserV1
+		err error
+	)
+	switch codec {
+	case codecJSON:
+		err = json.Unmarshal(body, &v)
+	case codecMsgpack:
+		err = msgpack.Unmarshal(body, &v)
+	default:
+		return user.User{}, ErrUnknownFormat
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	return user.User{ID: v.ID, Name: v.Name, Email: v.Email, Version: v.Version, CreatedAt: v.CreatedAt}, nil
+}
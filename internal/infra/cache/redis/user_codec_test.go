@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+)
+
+func testUser() user.User {
+	return user.User{
+		ID: 42, Name: strings.Repeat("n", 64), Email: "a@example.com", Version: 3,
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func equalUser(a, b user.User) bool {
+	return a.ID == b.ID && a.Name == b.Name && a.Email == b.Email && a.Version == b.Version && a.CreatedAt.Equal(b.CreatedAt)
+}
+
+func TestUserCodecRoundTrip(t *testing.T) {
+	u := testUser()
+	for _, name := range []string{"msgpack", "json"} {
+		for _, compressOver := range []int{0, 16} {
+			c, err := NewUserCodec(name, compressOver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := c.Encode(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != envelopeMagic || b[1] != userFormatVersion {
+				t.Errorf("%s/%d: header % x", name, compressOver, b[:envelopeHeaderLen])
+			}
+			if compressed := b[3]&flagS2 != 0; compressed != (compressOver > 0) {
+				t.Errorf("%s/%d: compressed = %v", name, compressOver, compressed)
+			}
+
+			got, err := c.Decode(b)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", name, compressOver, err)
+			}
+			if !equalUser(got, u) {
+				t.Errorf("%s/%d: decoded %+v, want %+v", name, compressOver, got, u)
+			}
+		}
+	}
+}
+
+// 读取按信封里的 codec 解码：切换配置后旧值仍然可读
+func TestUserCodecReadsOtherCodec(t *testing.T) {
+	u := testUser()
+	js, _ := NewUserCodec("json", 16)
+	mp, _ := NewUserCodec("msgpack", 0)
+	b, err := js.Encode(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := mp.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalUser(got, u) {
+		t.Errorf("decoded %+v, want %+v", got, u)
+	}
+}
+
+func TestUserCodecRejects(t *testing.T) {
+	c, _ := NewUserCodec("msgpack", 0)
+	valid, err := c.Encode(testUser())
+	if err != nil {
+		t.Fatal(err)
+	}
+	withHeader := func(i int, v byte) []byte {
+		b := append([]byte(nil), valid...)
+		b[i] = v
+		return b
+	}
+
+	for name, b := range map[string][]byte{
+		"short":          {envelopeMagic, userFormatVersion},
+		"pre-envelope":   []byte(`{"id":42,"name":"a"}`),
+		"future version": withHeader(1, userFormatVersion+1),
+		"unknown codec":  withHeader(2, 9),
+	} {
+		if _, err := c.Decode(b); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("%s: err = %v, want ErrUnknownFormat", name, err)
+		}
+	}
+	if _, err := c.Decode(withHeader(3, flagS2)); err == nil {
+		t.Error("corrupt compressed body decoded")
+	}
+	if _, err := NewUserCodec("gob", 0); err == nil {
+		t.Error("unknown codec name accepted")
+	}
+}
+
+func newTestUserCache(t *testing.T) (*UserCache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	codec, err := NewUserCodec("msgpack", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewUserCache(rdb, codec), mr
+}
+
+func TestUserCacheRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestUserCache(t)
+	u := testUser()
+
+	if err := c.Set(ctx, u, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, ttl, hit, err := c.GetWithTTL(ctx, u.ID)
+	if err != nil || !hit || !equalUser(got, u) {
+		t.Fatalf("GetWithTTL = %+v, %v, %v", got, hit, err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl = %v", ttl)
+	}
+}
+
+// 解不开的值（旧格式、别的版本写的）当 miss 处理并删掉，下次回源重新填
+func TestUserCacheDropsUndecodable(t *testing.T) {
+	ctx := context.Background()
+	c, mr := newTestUserCache(t)
+
+	for name, val := range map[string]string{
+		"pre-envelope JSON": `{"id":42,"name":"a"}`,
+		"future version":    string([]byte{envelopeMagic, userFormatVersion + 1, codecMsgpack, 0}),
+	} {
+		if err := mr.Set(c.key(42), val); err != nil {
+			t.Fatal(err)
+		}
+		if _, hit, err := c.Get(ctx, 42); hit || err != nil {
+			t.Errorf("%s: Get = %v, %v, want miss", name, hit, err)
+		}
+		if mr.Exists(c.key(42)) {
+			t.Errorf("%s: undecodable key not deleted", name)
+		}
+	}
+
+	if err := mr.Set(c.key(43), "garbage"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, hit, err := c.GetWithTTL(ctx, 43); hit || err != nil {
+		t.Errorf("GetWithTTL = %v, %v, want miss", hit, err)
+	}
+	if mr.Exists(c.key(43)) {
+		t.Error("GetWithTTL: undecodable key not deleted")
+	}
+}
@@ -0,0 +1,149 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/cache/local"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// Invalidator：通知其他副本清理本地缓存（比如 redis.InvalidationBus）
+type Invalidator interface {
+	Publish(ctx context.Context, id uint64) error
+}
+
+type entry struct {
+	u         user.User
+	notFound  bool
+	l2Expires time.Time // L2 的过期时间（未知则为零值），给提前刷新用
+}
+
+// UserCache：进程内 LRU（L1）+ 共享缓存（L2，通常是 redis.UserCache）
+// L1 TTL 应远小于 L2，Del/Evict 会广播失效，其他副本最多读到 L1 TTL 内的旧值；
+// Set/SetNotFound 只是回源填充，不广播（否则每次 miss 都会清掉所有副本的 L1，包括刚填好的这一份），
+// 数据变更由 worker 同步缓存后统一发失效通知
+type UserCache struct {
+	l1    *local.LRU[uint64, entry]
+	l1TTL time.Duration
+	l2    user.Cache
+	inv   Invalidator
+}
+
+func NewUserCache(l2 user.Cache, inv Invalidator, size int, l1TTL time.Duration) *UserCache {
+	return &UserCache{
+		l1:    local.NewLRU[uint64, entry](size),
+		l1TTL: l1TTL,
+		l2:    l2,
+		inv:   inv,
+	}
+}
+
+func (c *UserCache) Get(ctx context.Context, id uint64) (user.User, bool, error) {
+	u, _, hit, err := c.GetWithTTL(ctx, id)
+	return u, hit, err
+}
+
+func (c *UserCache) GetWithTTL(ctx context.Context, id uint64) (user.User, time.Duration, bool, error) {
+	if e, ok := c.l1.Get(id); ok {
+		metrics.UserCacheL1HitTotal.Add(1)
+		return c.result(e)
+	}
+	metrics.UserCacheL1MissTotal.Add(1)
+
+	var (
+		u         user.User
+		remaining time.Duration
+		hit       bool
+		err       error
+	)
+	if tc, ok := c.l2.(user.TTLCache); ok {
+		u, remaining, hit, err = tc.GetWithTTL(ctx, id)
+	} else {
+		u, hit, err = c.l2.Get(ctx, id)
+	}
+
+	notFound := errors.Is(err, user.ErrNotFound)
+	if (err != nil && !notFound) || !hit {
+		metrics.UserCacheL2MissTotal.Add(1)
+		return user.User{}, 0, false, err
+	}
+	metrics.UserCacheL2HitTotal.Add(1)
+
+	e := entry{u: u, notFound: notFound}
+	if remaining > 0 {
+		e.l2Expires = time.Now().Add(remaining)
+	}
+	c.l1.Set(id, e, c.localTTL(remaining))
+	return c.result(e)
+}
+
+func (c *UserCache) Set(ctx context.Context, u user.User, ttl time.Duration) error {
+	if err := c.l2.Set(ctx, u, ttl); err != nil {
+		return err
+	}
+	c.l1.Set(u.ID, entry{u: u, l2Expires: time.Now().Add(ttl)}, c.localTTL(ttl))
+	return nil
+}
+
+func (c *UserCache) SetNotFound(ctx context.Context, id uint64, ttl time.Duration) error {
+	if err := c.l2.SetNotFound(ctx, id, ttl); err != nil {
+		return err
+	}
+	c.l1.Set(id, entry{notFound: true, l2Expires: time.Now().Add(ttl)}, c.localTTL(ttl))
+	return nil
+}
+
+func (c *UserCache) Del(ctx context.Context, id uint64) error {
+	c.l1.Del(id)
+	if err := c.l2.Del(ctx, id); err != nil {
+		return err
+	}
+	return c.broadcast(ctx, id)
+}
+
+func (c *UserCache) Evict(ctx context.Context, id uint64, version uint64) error {
+	c.l1.Del(id)
+	if err := c.l2.Evict(ctx, id, version); err != nil {
+		return err
+	}
+	return c.broadcast(ctx, id)
+}
+
+// EvictLocal 只清本地副本（收到其他副本的失效通知时调用）
+func (c *UserCache) EvictLocal(id uint64) {
+	c.l1.Del(id)
+}
+
+// PurgeLocal 清空本地副本
+func (c *UserCache) PurgeLocal() {
+	c.l1.Purge()
+}
+
+func (c *UserCache) broadcast(ctx context.Context, id uint64) error {
+	if c.inv == nil {
+		return nil
+	}
+	return c.inv.Publish(ctx, id)
+}
+
+// localTTL：L1 不能比 L2 活得更久
+func (c *UserCache) localTTL(l2TTL time.Duration) time.Duration {
+	if l2TTL > 0 && l2TTL < c.l1TTL {
+		return l2TTL
+	}
+	return c.l1TTL
+}
+
+func (c *UserCache) result(e entry) (user.User, time.Duration, bool, error) {
+	var remaining time.Duration
+	if !e.l2Expires.IsZero() {
+		remaining = time.Until(e.l2Expires)
+	}
+	if e.notFound {
+		return user.User{}, remaining, true, user.ErrNotFound
+	}
+	return e.u, remaining, true, nil
+}
@@ -0,0 +1,241 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/cache/redis"
+)
+
+// fakeL2：内存版共享缓存，记录读次数
+type fakeL2 struct {
+	mu       sync.Mutex
+	users    map[uint64]user.User
+	notFound map[uint64]bool
+	ttl      time.Duration
+	gets     int
+}
+
+func newFakeL2() *fakeL2 {
+	return &fakeL2{users: make(map[uint64]user.User), notFound: make(map[uint64]bool), ttl: time.Minute}
+}
+
+func (f *fakeL2) Get(ctx context.Context, id uint64) (user.User, bool, error) {
+	u, _, hit, err := f.GetWithTTL(ctx, id)
+	return u, hit, err
+}
+
+func (f *fakeL2) GetWithTTL(_ context.Context, id uint64) (user.User, time.Duration, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gets++
+	if f.notFound[id] {
+		return user.User{}, f.ttl, true, user.ErrNotFound
+	}
+	u, ok := f.users[id]
+	if !ok {
+		return user.User{}, 0, false, nil
+	}
+	return u, f.ttl, true, nil
+}
+
+func (f *fakeL2) Set(_ context.Context, u user.User, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users[u.ID] = u
+	delete(f.notFound, u.ID)
+	return nil
+}
+
+func (f *fakeL2) SetNotFound(_ context.Context, id uint64, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notFound[id] = true
+	return nil
+}
+
+func (f *fakeL2) Del(_ context.Context, id uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.users, id)
+	delete(f.notFound, id)
+	return nil
+}
+
+func (f *fakeL2) Evict(ctx context.Context, id uint64, _ uint64) error { return f.Del(ctx, id) }
+
+func (f *fakeL2) getCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets
+}
+
+type fakeInvalidator struct {
+	mu  sync.Mutex
+	ids []uint64
+}
+
+func (f *fakeInvalidator) Publish(_ context.Context, id uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ids = append(f.ids, id)
+	return nil
+}
+
+func TestUserCacheReadThrough(t *testing.T) {
+	ctx := context.Background()
+	l2 := newFakeL2()
+	l2.users[1] = user.User{ID: 1, Name: "a", Version: 1}
+	l2.notFound[2] = true
+	inv := &fakeInvalidator{}
+	c := NewUserCache(l2, inv, 16, time.Minute)
+
+	for range 3 {
+		u, remaining, hit, err := c.GetWithTTL(ctx, 1)
+		if err != nil || !hit || u.Name != "a" {
+			t.Fatalf("Get(1) = %+v, %v, %v", u, hit, err)
+		}
+		if remaining <= 0 || remaining > time.Minute {
+			t.Errorf("remaining = %v, want L2 TTL", remaining)
+		}
+	}
+	// 负缓存也进 L1
+	for range 3 {
+		if _, hit, err := c.Get(ctx, 2); !hit || !errors.Is(err, user.ErrNotFound) {
+			t.Fatalf("Get(2) = %v, %v, want cached ErrNotFound", hit, err)
+		}
+	}
+	if got := l2.getCount(); got != 2 {
+		t.Errorf("L2 reads = %d, want one per id", got)
+	}
+
+	// 两边都没有：miss，不缓存
+	if _, hit, err := c.Get(ctx, 3); hit || err != nil {
+		t.Errorf("Get(3) = %v, %v, want miss", hit, err)
+	}
+	if _, hit, _ := c.Get(ctx, 3); hit {
+		t.Error("miss cached in L1")
+	}
+
+	// 回源填充写两层，但不广播
+	if err := c.Set(ctx, user.User{ID: 3, Name: "c"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	before := l2.getCount()
+	if u, hit, _ := c.Get(ctx, 3); !hit || u.Name != "c" {
+		t.Errorf("Get(3) after Set = %+v, %v", u, hit)
+	}
+	if l2.getCount() != before {
+		t.Error("Get after Set went to L2, want L1 hit")
+	}
+	if len(inv.ids) != 0 {
+		t.Errorf("fills broadcast invalidations %v", inv.ids)
+	}
+}
+
+func TestUserCacheInvalidate(t *testing.T) {
+	ctx := context.Background()
+	l2 := newFakeL2()
+	l2.users[1] = user.User{ID: 1, Name: "a", Version: 1}
+	inv := &fakeInvalidator{}
+	c := NewUserCache(l2, inv, 16, time.Minute)
+
+	if _, hit, _ := c.Get(ctx, 1); !hit {
+		t.Fatal("Get(1) missed")
+	}
+	if err := c.Evict(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit, _ := c.Get(ctx, 1); hit {
+		t.Error("Get(1) hit after Evict")
+	}
+	if err := c.Del(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.ids) != 2 || inv.ids[0] != 1 || inv.ids[1] != 1 {
+		t.Errorf("broadcast %v, want [1 1]", inv.ids)
+	}
+}
+
+func TestLocalTTLNeverOutlivesL2(t *testing.T) {
+	c := NewUserCache(newFakeL2(), nil, 16, 5*time.Second)
+	for _, tt := range []struct{ l2, want time.Duration }{
+		{time.Minute, 5 * time.Second},
+		{time.Second, time.Second},
+		{0, 5 * time.Second}, // L2 TTL 未知
+	} {
+		if got := c.localTTL(tt.l2); got != tt.want {
+			t.Errorf("localTTL(%v) = %v, want %v", tt.l2, got, tt.want)
+		}
+	}
+}
+
+// 两个副本共享 Redis：一边更新后通过 pub/sub 让另一边的 L1 失效
+func TestUserCacheInvalidationAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	codec, err := redis.NewUserCodec("msgpack", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	replica := func() *UserCache {
+		bus := redis.NewInvalidationBus(rdb, "cache:invalidate:user")
+		c := NewUserCache(redis.NewUserCache(rdb, codec), bus, 16, time.Minute)
+		subscribed := make(chan struct{}, 1)
+		go bus.Subscribe(ctx, log, c.EvictLocal, func() {
+			c.PurgeLocal()
+			subscribed <- struct{}{}
+		})
+		select {
+		case <-subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("subscribe timed out")
+		}
+		return c
+	}
+	a, b := replica(), replica()
+
+	if err := a.Set(ctx, user.User{ID: 1, Name: "old", Version: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if u, hit, err := b.Get(ctx, 1); err != nil || !hit || u.Name != "old" {
+		t.Fatalf("b.Get = %+v, %v, %v", u, hit, err)
+	}
+
+	// 更新：a 清掉 L2 并广播；之后 b 的 L1 不应该再返回旧值
+	if err := a.Evict(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set(ctx, user.User{ID: 1, Name: "new", Version: 2}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		u, hit, err := b.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hit && u.Name == "new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("b still reads %+v (hit %v), want new", u, hit)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
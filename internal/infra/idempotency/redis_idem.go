@@ -2,22 +2,83 @@ package idempotency
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	goredis "github.com/redis/go-redis/v9"
 )
 
+// State：Begin 的结果
+type State int
+
+const (
+	Acquired   State = iota // 拿到处理租约，处理完调用 Complete 或 Abandon
+	InProgress              // 别处正在处理：等待或退避，不能直接 ack
+	Done                    // 已经处理完，跳过
+)
+
+// 租约值带前缀和随机 token，只有持有者能 Abandon；其他任何值都视为已完成
+const leasePrefix = "lease:"
+
+type Lease struct {
+	key   string
+	token string
+}
+
 type Store struct {
-	rdb *goredis.Client
+	rdb goredis.UniversalClient
 }
 
-func New(rdb *goredis.Client) *Store {
+func New(rdb goredis.UniversalClient) *Store {
 	return &Store{rdb: rdb}
 }
 
-// TryMarkProcessed：返回 true 表示首次处理；false 表示重复（应跳过）
-func (s *Store) TryMarkProcessed(ctx context.Context, key string, ttl time.Duration) (bool, error) {
-	ok, err := s.rdb.SetNX(ctx, fmt.Sprintf("inbox:%s", key), "1", ttl).Result()
-	return ok, err
+// Begin 尝试拿处理租约；ttl 要长于一次处理的耗时，持有者崩溃后到期即可被重新领取
+func (s *Store) Begin(ctx context.Context, key string, ttl time.Duration) (State, Lease, error) {
+	l := Lease{key: fmt.Sprintf("inbox:%s", key), token: newToken()}
+	ok, err := s.rdb.SetNX(ctx, l.key, leasePrefix+l.token, ttl).Result()
+	if err != nil {
+		return 0, Lease{}, err
+	}
+	if ok {
+		return Acquired, l, nil
+	}
+
+	v, err := s.rdb.Get(ctx, l.key).Result()
+	switch {
+	case err == goredis.Nil:
+		return InProgress, Lease{}, nil // 刚好过期：下次 Begin 再抢
+	case err != nil:
+		return 0, Lease{}, err
+	case strings.HasPrefix(v, leasePrefix):
+		return InProgress, Lease{}, nil
+	default:
+		return Done, Lease{}, nil
+	}
+}
+
+// Complete 标记为已完成，保留 ttl；副作用已经提交，租约即使已过期也照样覆盖
+func (s *Store) Complete(ctx context.Context, l Lease, ttl time.Duration) error {
+	return s.rdb.Set(ctx, l.key, "done", ttl).Err()
+}
+
+// abandonScript：只删除自己的租约，过期后被别人拿走的不动
+var abandonScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+  return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Abandon 放弃租约（处理失败、转 DLQ 或重投），让重投的消息能立即再处理
+func (s *Store) Abandon(ctx context.Context, l Lease) error {
+	return abandonScript.Run(ctx, s.rdb, []string{l.key}, leasePrefix+l.token).Err()
+}
+
+func newToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
 }
@@ -0,0 +1,82 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return New(rdb), mr
+}
+
+func begin(t *testing.T, s *Store, want State) Lease {
+	t.Helper()
+	st, l, err := s.Begin(context.Background(), "msg-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != want {
+		t.Fatalf("Begin = %v, want %v", st, want)
+	}
+	return l
+}
+
+func TestLeaseExpires(t *testing.T) {
+	s, mr := newTestStore(t)
+	begin(t, s, Acquired)
+	begin(t, s, InProgress)
+
+	// 持有者崩溃：租约到期后别人可以重新领取
+	mr.FastForward(time.Minute + time.Second)
+	begin(t, s, Acquired)
+}
+
+func TestCompleteSkipsRedelivery(t *testing.T) {
+	s, _ := newTestStore(t)
+	l := begin(t, s, Acquired)
+	if err := s.Complete(context.Background(), l, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	begin(t, s, Done)
+}
+
+func TestAbandonAllowsReprocessing(t *testing.T) {
+	s, _ := newTestStore(t)
+	l := begin(t, s, Acquired)
+	if err := s.Abandon(context.Background(), l); err != nil {
+		t.Fatal(err)
+	}
+	begin(t, s, Acquired)
+}
+
+// 租约过期后被别人拿走：旧持有者的 Abandon 不动新租约，Complete 照样标记完成
+func TestStaleLease(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestStore(t)
+	stale := begin(t, s, Acquired)
+	mr.FastForward(time.Minute + time.Second)
+	fresh := begin(t, s, Acquired)
+
+	if err := s.Abandon(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	begin(t, s, InProgress)
+
+	// 旧持有者的副作用已经提交：以它为准，新持有者再放弃也不能删掉完成标记
+	if err := s.Complete(ctx, stale, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	begin(t, s, Done)
+	if err := s.Abandon(ctx, fresh); err != nil {
+		t.Fatal(err)
+	}
+	begin(t, s, Done)
+}
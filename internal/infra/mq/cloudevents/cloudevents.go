@@ -0,0 +1,221 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// CloudEvents 1.0 Kafka 协议绑定，生产端（outbox 投递）和消费端共用，避免两边各写一套再次跑偏：
+//   - binary：value 是 data 原文，属性放在 ce_ 前缀的 header，datacontenttype 放 content-type
+//   - structured：value 是整个 JSON 信封，content-type 为 application/cloudevents+json
+//
+// 只支持 JSON data
+const (
+	SpecVersion           = "1.0"
+	ContentTypeJSON       = "application/json"
+	ContentTypeStructured = "application/cloudevents+json"
+
+	headerPrefix      = "ce_"
+	headerContentType = "content-type"
+)
+
+type Mode string
+
+const (
+	Binary     Mode = "binary"
+	Structured Mode = "structured"
+)
+
+var ErrInvalid = errors.New("invalid cloudevent")
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case Binary, Structured:
+		return m, nil
+	case "":
+		return Binary, nil
+	default:
+		return "", fmt.Errorf("unknown cloudevents mode %q", s)
+	}
+}
+
+// Event：上下文属性 + data
+type Event struct {
+	ID              string
+	Source          string
+	Type            string
+	Subject         string
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	Data            json.RawMessage
+
+	// Headers：不属于 CloudEvents 的 Kafka header（request_id、retry 等），原样透传
+	Headers map[string]string
+}
+
+// Validate 检查必填属性
+func (e Event) Validate() error {
+	switch {
+	case e.ID == "":
+		return fmt.Errorf("%w: missing id", ErrInvalid)
+	case e.Source == "":
+		return fmt.Errorf("%w: missing source", ErrInvalid)
+	case e.Type == "":
+		return fmt.Errorf("%w: missing type", ErrInvalid)
+	}
+	return nil
+}
+
+// envelope：structured 模式的 JSON 信封
+type envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time,omitzero"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// Encode 返回 Kafka value 和 header；Key / Topic 由调用方设置
+func Encode(e Event, mode Mode) ([]byte, []kgo.RecordHeader, error) {
+	if err := e.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if e.DataContentType == "" {
+		e.DataContentType = ContentTypeJSON
+	}
+
+	hs := make([]kgo.RecordHeader, 0, len(e.Headers)+8)
+	for k, v := range e.Headers {
+		hs = append(hs, kgo.RecordHeader{Key: k, Value: []byte(v)})
+	}
+
+	if mode == Structured {
+		b, err := json.Marshal(envelope{
+			SpecVersion:     SpecVersion,
+			ID:              e.ID,
+			Source:          e.Source,
+			Type:            e.Type,
+			Subject:         e.Subject,
+			Time:            e.Time.UTC(),
+			DataContentType: e.DataContentType,
+			DataSchema:      e.DataSchema,
+			Data:            e.Data,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return b, append(hs, kgo.RecordHeader{Key: headerContentType, Value: []byte(ContentTypeStructured)}), nil
+	}
+
+	add := func(k, v string) {
+		if v != "" {
+			hs = append(hs, kgo.RecordHeader{Key: k, Value: []byte(v)})
+		}
+	}
+	add(headerPrefix+"specversion", SpecVersion)
+	add(headerPrefix+"id", e.ID)
+	add(headerPrefix+"source", e.Source)
+	add(headerPrefix+"type", e.Type)
+	add(headerPrefix+"subject", e.Subject)
+	if !e.Time.IsZero() {
+		add(headerPrefix+"time", e.Time.UTC().Format(time.RFC3339Nano))
+	}
+	add(headerPrefix+"dataschema", e.DataSchema)
+	add(headerContentType, e.DataContentType)
+	return e.Data, hs, nil
+}
+
+// LegacyTypes：topic -> 事件类型。引入 CloudEvents 之前 outbox 直接把 payload 原文作为 value 投递，
+// header 只有 request_id，消息本身不带类型，只能按 topic 认
+type LegacyTypes map[string]string
+
+// Decode 按 content-type / ce_specversion 识别模式；两者都没有时按旧格式解析（见 decodeLegacy），
+// 滚动升级期间两种消息并存
+func Decode(r *kgo.Record, legacy LegacyTypes) (Event, error) {
+	e := Event{Headers: make(map[string]string)}
+	var ct string
+	attrs := make(map[string]string)
+	for _, h := range r.Headers {
+		k := strings.ToLower(h.Key)
+		switch {
+		case k == headerContentType:
+			ct = string(h.Value)
+		case strings.HasPrefix(k, headerPrefix):
+			attrs[strings.TrimPrefix(k, headerPrefix)] = string(h.Value)
+		default:
+			e.Headers[h.Key] = string(h.Value)
+		}
+	}
+	spec := attrs["specversion"]
+
+	switch {
+	case strings.HasPrefix(ct, ContentTypeStructured):
+		var env envelope
+		if err := json.Unmarshal(r.Value, &env); err != nil {
+			return Event{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		if env.SpecVersion != SpecVersion {
+			return Event{}, fmt.Errorf("%w: specversion %q", ErrInvalid, env.SpecVersion)
+		}
+		e.ID, e.Source, e.Type, e.Subject = env.ID, env.Source, env.Type, env.Subject
+		e.Time, e.DataContentType, e.DataSchema, e.Data = env.Time, env.DataContentType, env.DataSchema, env.Data
+		return e, e.Validate()
+
+	case spec != "":
+		if spec != SpecVersion {
+			return Event{}, fmt.Errorf("%w: specversion %q", ErrInvalid, spec)
+		}
+		e.ID, e.Source, e.Type, e.Subject = attrs["id"], attrs["source"], attrs["type"], attrs["subject"]
+		e.DataContentType, e.DataSchema, e.Data = ct, attrs["dataschema"], r.Value
+		if t := attrs["time"]; t != "" {
+			var err error
+			if e.Time, err = time.Parse(time.RFC3339Nano, t); err != nil {
+				return Event{}, fmt.Errorf("%w: time: %v", ErrInvalid, err)
+			}
+		}
+		return e, e.Validate()
+
+	default:
+		return decodeLegacy(r, e, legacy)
+	}
+}
+
+// legacyEvent：旧版 kafka.Producer.Publish 的 JSON（event.Event）
+type legacyEvent struct {
+	Type       string          `json:"type"`
+	Key        string          `json:"key"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// decodeLegacy 解析引入 CloudEvents 之前的消息，没有 id / source（由调用方按 topic/partition/offset 补）：
+//   - outbox 投递的：value 是 payload 原文，类型按 topic 查 legacy
+//   - Producer.Publish 发的：value 是 {type, key, occurred_at, payload}，类型和 payload 都在里面
+func decodeLegacy(r *kgo.Record, e Event, legacy LegacyTypes) (Event, error) {
+	if !json.Valid(r.Value) {
+		return Event{}, fmt.Errorf("%w: value is not JSON", ErrInvalid)
+	}
+	e.DataContentType = ContentTypeJSON
+
+	var le legacyEvent
+	if json.Unmarshal(r.Value, &le) == nil && le.Type != "" && len(le.Payload) > 0 {
+		e.Type, e.Subject, e.Time, e.Data = le.Type, le.Key, le.OccurredAt, le.Payload
+		return e, nil
+	}
+
+	e.Type, e.Data = legacy[r.Topic], r.Value
+	if e.Type == "" {
+		return Event{}, fmt.Errorf("%w: no cloudevents attributes and no legacy type for topic %q", ErrInvalid, r.Topic)
+	}
+	return e, nil
+}
@@ -0,0 +1,56 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+)
+
+// outbox 表没有单独的属性列：subject / dataschema 和普通 header 一起存进 headers 列，
+// 其余属性投递时由行本身决定（id = 行 id，type = event_type，time = 写入时间）
+const (
+	outboxSubject    = headerPrefix + "subject"
+	outboxDataSchema = headerPrefix + "dataschema"
+)
+
+// OutboxHeaders：OutboxStore.Add 写入 headers 列的内容
+func OutboxHeaders(m event.OutboxMessage) map[string]string {
+	hs := make(map[string]string, len(m.Headers)+2)
+	for k, v := range m.Headers {
+		hs[k] = v
+	}
+	if m.Subject != "" {
+		hs[outboxSubject] = m.Subject
+	}
+	if m.DataSchema != "" {
+		hs[outboxDataSchema] = m.DataSchema
+	}
+	return hs
+}
+
+// FromOutbox 组装投递用的事件：id 用 outbox 行 id，重发时不变，消费端按它去重
+func FromOutbox(r event.OutboxRecord, source string) (Event, error) {
+	var hs map[string]string
+	if len(r.Headers) > 0 {
+		if err := json.Unmarshal(r.Headers, &hs); err != nil {
+			return Event{}, fmt.Errorf("%w: headers: %v", ErrInvalid, err)
+		}
+	}
+
+	e := Event{
+		ID:              strconv.FormatUint(r.ID, 10),
+		Source:          source,
+		Type:            r.Type,
+		Subject:         hs[outboxSubject],
+		Time:            r.CreatedAt,
+		DataContentType: ContentTypeJSON,
+		DataSchema:      hs[outboxDataSchema],
+		Data:            r.Payload,
+	}
+	delete(hs, outboxSubject)
+	delete(hs, outboxDataSchema)
+	e.Headers = hs
+	return e, e.Validate()
+}
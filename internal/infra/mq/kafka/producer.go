@@ -3,7 +3,9 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/twmb/franz-go/pkg/kgo"
 
@@ -53,6 +55,49 @@ func (p *Producer) PublishRaw(ctx context.Context, rec *kgo.Record) error {
 	return res.FirstErr()
 }
 
+// ErrSkipped：同 topic、同 key 的前一条消息发送失败，这条没有发送
+var ErrSkipped = errors.New("kafka: skipped after an earlier record with the same key failed")
+
+// PublishOrdered 按 (topic, key) 分组投递，全部结束后返回，errs[i] 对应 recs[i]：
+//   - 不同 key 并发发送，由客户端按分区攒批
+//   - 同 key 的消息上一条 ack 之后才发下一条；某条失败后剩下的不再发送（ErrSkipped），
+//     重试时它们仍排在失败的那条后面
+//   - 空 key 的消息不要求顺序，直接异步发送
+func (p *Producer) PublishOrdered(ctx context.Context, recs []*kgo.Record) []error {
+	errs := make([]error, len(recs))
+	type chainKey struct{ topic, key string }
+	chains := make(map[chainKey][]int)
+	var wg sync.WaitGroup
+	for i, rec := range recs {
+		if len(rec.Key) == 0 {
+			wg.Add(1)
+			p.cl.Produce(ctx, rec, func(_ *kgo.Record, err error) {
+				errs[i] = err
+				wg.Done()
+			})
+			continue
+		}
+		k := chainKey{rec.Topic, string(rec.Key)}
+		chains[k] = append(chains[k], i)
+	}
+
+	for _, chain := range chains {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n, i := range chain {
+				if errs[i] = p.cl.ProduceSync(ctx, recs[i]).FirstErr(); errs[i] != nil {
+					for _, j := range chain[n+1:] {
+						errs[j] = ErrSkipped
+					}
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
 
 func (p *Producer) Close() error {
 	p.cl.Close()
@@ -0,0 +1,309 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migration：一对 up/down SQL，文件名形如 0001_init.up.sql / 0001_init.down.sql
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // up SQL 的 sha256，防止已执行的文件被改动
+}
+
+// Status：单个 migration 的执行状态
+type Status struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	Mismatch  bool // 已执行，但文件 checksum 变了
+}
+
+// Dialect：不同数据库的差异点（锁、占位符、DDL 是否可回滚）
+type Dialect interface {
+	// Lock 在 conn 上获取全局迁移锁，保证多个副本不会并发迁移
+	Lock(ctx context.Context, conn *sql.Conn) error
+	Unlock(ctx context.Context, conn *sql.Conn) error
+	CreateTableSQL() string
+	Placeholder(n int) string
+	TransactionalDDL() bool
+}
+
+var (
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+)
+
+var fileRe = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+// Load 从 fsys 的 dir 目录读取全部 migration，按版本号排序
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, e := range entries {
+		m := fileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		v, _ := strconv.ParseUint(m[1], 10, 64)
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[v]
+		if !ok {
+			mg = &Migration{Version: v, Name: m[2]}
+			byVersion[v] = mg
+		} else if mg.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", v, mg.Name, m[2])
+		}
+		if m[3] == "up" {
+			mg.Up = string(b)
+			sum := sha256.Sum256(b)
+			mg.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mg.Version, mg.Name)
+		}
+		res = append(res, *mg)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up 执行所有未执行的 migration，返回执行数量
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var target uint64
+	if n := len(m.migrations); n > 0 {
+		target = m.migrations[n-1].Version
+	}
+	return m.To(ctx, target)
+}
+
+// Down 回滚最近 steps 个已执行的 migration
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	n := 0
+	err := m.locked(ctx, func(conn *sql.Conn, done map[uint64]applied) error {
+		for i := len(m.migrations) - 1; i >= 0 && n < steps; i-- {
+			mg := m.migrations[i]
+			if _, ok := done[mg.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mg, false); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// To 迁移到指定版本：比当前新则 up，比当前旧则 down（target=0 表示全部回滚）
+func (m *Migrator) To(ctx context.Context, target uint64) (int, error) {
+	if target != 0 && m.find(target) < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+
+	n := 0
+	err := m.locked(ctx, func(conn *sql.Conn, done map[uint64]applied) error {
+		for _, mg := range m.migrations {
+			a, ok := done[mg.Version]
+			if ok && a.checksum != mg.Checksum {
+				return fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, mg.Version, mg.Name)
+			}
+		}
+
+		// down：从新到旧
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := done[mg.Version]; ok && mg.Version > target {
+				if err := m.apply(ctx, conn, mg, false); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		// up：从旧到新
+		for _, mg := range m.migrations {
+			if _, ok := done[mg.Version]; !ok && mg.Version <= target {
+				if err := m.apply(ctx, conn, mg, true); err != nil {
+					return err
+				}
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var res []Status
+	err := m.locked(ctx, func(_ *sql.Conn, done map[uint64]applied) error {
+		for _, mg := range m.migrations {
+			st := Status{Version: mg.Version, Name: mg.Name}
+			if a, ok := done[mg.Version]; ok {
+				st.Applied, st.AppliedAt, st.Mismatch = true, a.appliedAt, a.checksum != mg.Checksum
+			}
+			res = append(res, st)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// locked：拿锁 -> 建表 -> 读已执行列表 -> fn
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, done map[uint64]applied) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := m.dialect.Lock(ctx, conn); err != nil {
+		return fmt.Errorf("migration lock: %w", err)
+	}
+	defer func() {
+		// ctx 可能已取消，解锁用独立 ctx
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = m.dialect.Unlock(unlockCtx, conn)
+	}()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.CreateTableSQL()); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	done := map[uint64]applied{}
+	for rows.Next() {
+		var (
+			v uint64
+			a applied
+		)
+		if err := rows.Scan(&v, &a.checksum, &a.appliedAt); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		done[v] = a
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return fn(conn, done)
+}
+
+// execer：*sql.Conn 和 *sql.Tx 共有的方法
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mg Migration, up bool) error {
+	script := mg.Up
+	if !up {
+		if mg.Down == "" {
+			return fmt.Errorf("migration %d_%s has no down file", mg.Version, mg.Name)
+		}
+		script = mg.Down
+	}
+
+	var (
+		ex execer = conn
+		tx *sql.Tx
+	)
+	if m.dialect.TransactionalDDL() {
+		var err error
+		if tx, err = conn.BeginTx(ctx, nil); err != nil {
+			return err
+		}
+		ex = tx
+	}
+
+	err := func() error {
+		for _, stmt := range SplitStatements(script) {
+			if _, err := ex.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		p1, p2, p3 := m.dialect.Placeholder(1), m.dialect.Placeholder(2), m.dialect.Placeholder(3)
+		if up {
+			_, err := ex.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES (`+p1+`, `+p2+`, `+p3+`)`,
+				mg.Version, mg.Name, mg.Checksum)
+			return err
+		}
+		_, err := ex.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = `+p1, mg.Version)
+		return err
+	}()
+	if err != nil {
+		if tx != nil {
+			_ = tx.Rollback()
+		}
+		dir := "up"
+		if !up {
+			dir = "down"
+		}
+		return fmt.Errorf("migration %d_%s %s: %w", mg.Version, mg.Name, dir, err)
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+func (m *Migrator) find(version uint64) int {
+	for i, mg := range m.migrations {
+		if mg.Version == version {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,60 @@
+package migrate
+
+import "strings"
+
+// SplitStatements 按分号切分 SQL 脚本（忽略引号和注释里的分号），不依赖驱动的 multiStatements
+func SplitStatements(script string) []string {
+	var (
+		res   []string
+		cur   strings.Builder
+		quote rune // 当前所在的引号：' " `，0 表示不在引号里
+	)
+
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			res = append(res, s)
+		}
+		cur.Reset()
+	}
+
+	rs := []rune(script)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+
+		if quote != 0 {
+			cur.WriteRune(r)
+			if r == '\\' && i+1 < len(rs) {
+				i++
+				cur.WriteRune(rs[i])
+			} else if r == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			cur.WriteRune(r)
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			// 行注释：跳到行尾
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			cur.WriteRune('\n')
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			end := strings.Index(string(rs[i+2:]), "*/")
+			if end < 0 {
+				i = len(rs)
+			} else {
+				i += 2 + len([]rune(string(rs[i+2:])[:end])) + 1
+			}
+		case r == ';':
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return res
+}
@@ -3,6 +3,8 @@ package mysql
 import (
 	"context"
 	"database/sql"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/audit"
 )
 
 type AuditRepo struct {
@@ -13,9 +15,34 @@ func NewAuditRepo(db *sql.DB) *AuditRepo {
 	return &AuditRepo{db: db}
 }
 
-func (r *AuditRepo) Insert(ctx context.Context, eventType, eventKey string, payload []byte) error {
+func (r *AuditRepo) Insert(ctx context.Context, eventType, eventKey, dataSchema string, payload []byte) error {
 	ex := getExecer(r.db, ctx)
-	const q = `INSERT INTO audit_logs (event_type, event_key, payload) VALUES (?, ?, ?)`
-	_, err := ex.ExecContext(ctx, q, eventType, eventKey, payload)
+	const q = `/* audit.insert */ INSERT INTO audit_logs (event_type, event_key, data_schema, payload) VALUES (?, ?, ?, ?)`
+	_, err := ex.ExecContext(ctx, q, eventType, eventKey, dataSchema, payload)
 	return err
 }
+
+func (r *AuditRepo) List(ctx context.Context, afterID uint64, eventType string, limit int) ([]audit.Log, error) {
+	const q = `/* audit.list */
+SELECT id, event_type, event_key, data_schema, payload, created_at
+FROM audit_logs
+WHERE id > ? AND (? = '' OR event_type = ?)
+ORDER BY id
+LIMIT ?`
+
+	rows, err := r.db.QueryContext(ctx, q, afterID, eventType, eventType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []audit.Log
+	for rows.Next() {
+		var l audit.Log
+		if err := rows.Scan(&l.ID, &l.EventType, &l.EventKey, &l.DataSchema, &l.Payload, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
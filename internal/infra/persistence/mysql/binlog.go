@@ -0,0 +1,291 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	driver "github.com/go-sql-driver/mysql"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+)
+
+var (
+	// ErrBinlogUnavailable：没开 binlog、不是 ROW 格式或没有复制权限，调用方应回退到轮询
+	ErrBinlogUnavailable = errors.New("binlog unavailable")
+	// ErrBinlogLocked：同名中继已经在别的副本上运行
+	ErrBinlogLocked = errors.New("binlog relay locked by another instance")
+)
+
+type BinlogConfig struct {
+	DSN      string // 主库 DSN，账号需要 REPLICATION SLAVE + REPLICATION CLIENT 权限
+	ServerID uint32 // 以从库身份注册，不能和任何真实副本的 server_id 冲突
+	Name     string // 位点和互斥锁的名字，同一个库上多个中继用不同名字
+	Logger   *slog.Logger
+}
+
+type BinlogPosition struct {
+	File string
+	Pos  uint32
+
+	Time time.Time // 事务提交时间（binlog 事件时间戳，秒级），只用于计算延迟，不落库
+}
+
+// OutboxBinlog：订阅主库 binlog，按事务交出 outbox 新插入行的 id
+// binlog 只用来拿到「哪些行、什么顺序」，行内容和状态仍以表为准（见 Load）
+type OutboxBinlog struct {
+	db   *sql.DB
+	cfg  BinlogConfig
+	conf *driver.Config
+
+	lock     *sql.Conn // GET_LOCK 绑定在连接上，连接断开锁自动释放
+	syncer   *replication.BinlogSyncer
+	streamer *replication.BinlogStreamer
+	schema   string
+	idCol    int
+
+	file  string   // 当前 binlog 文件，随 RotateEvent 切换
+	txIDs []uint64 // 当前事务里读到的 id，读到 XID（提交）时交出
+}
+
+func NewOutboxBinlog(db *sql.DB, cfg BinlogConfig) (*OutboxBinlog, error) {
+	conf, err := driver.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("binlog dsn: %w", err)
+	}
+	if cfg.Name == "" {
+		cfg.Name = "outbox"
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	return &OutboxBinlog{db: db, cfg: cfg, conf: conf}, nil
+}
+
+// Start 检查 binlog、抢占中继锁、从保存的位点开始订阅；没有位点时从当前位置开始
+// 返回 ErrBinlogUnavailable / ErrBinlogLocked 时不需要 Close
+func (b *OutboxBinlog) Start(ctx context.Context) (BinlogPosition, error) {
+	if err := b.check(ctx); err != nil {
+		return BinlogPosition{}, err
+	}
+	if err := b.acquire(ctx); err != nil {
+		return BinlogPosition{}, err
+	}
+
+	pos, err := b.startPosition(ctx)
+	if err != nil {
+		_ = b.Close()
+		return BinlogPosition{}, err
+	}
+
+	host, port, err := net.SplitHostPort(b.conf.Addr)
+	if err != nil {
+		_ = b.Close()
+		return BinlogPosition{}, fmt.Errorf("%w: addr %q: %v", ErrBinlogUnavailable, b.conf.Addr, err)
+	}
+	p, _ := strconv.ParseUint(port, 10, 16)
+
+	b.syncer = replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:             b.cfg.ServerID,
+		Flavor:               gomysql.MySQLFlavor,
+		Host:                 host,
+		Port:                 uint16(p),
+		User:                 b.conf.User,
+		Password:             b.conf.Passwd,
+		TLSConfig:            b.conf.TLS,
+		HeartbeatPeriod:      10 * time.Second,
+		ReadTimeout:          30 * time.Second,
+		MaxReconnectAttempts: 3, // 超过后返回错误，由调用方回退到轮询
+		Logger:               b.cfg.Logger,
+	})
+	b.streamer, err = b.syncer.StartSync(gomysql.Position{Name: pos.File, Pos: pos.Pos})
+	if err != nil {
+		_ = b.Close()
+		return BinlogPosition{}, fmt.Errorf("%w: %v", ErrBinlogUnavailable, err)
+	}
+	b.file = pos.File
+	b.txIDs = nil
+	return pos, nil
+}
+
+func (b *OutboxBinlog) check(ctx context.Context) error {
+	var logBin int
+	var format string
+	if err := b.db.QueryRowContext(ctx, `/* outbox_cdc.check */ SELECT @@log_bin, @@binlog_format`).Scan(&logBin, &format); err != nil {
+		return fmt.Errorf("%w: %v", ErrBinlogUnavailable, err)
+	}
+	if logBin != 1 || format != "ROW" {
+		return fmt.Errorf("%w: log_bin=%d binlog_format=%s", ErrBinlogUnavailable, logBin, format)
+	}
+
+	const q = `/* outbox_cdc.columns */
+SELECT DATABASE(), ORDINAL_POSITION FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'outbox' AND COLUMN_NAME = 'id'`
+	var ordinal int
+	if err := b.db.QueryRowContext(ctx, q).Scan(&b.schema, &ordinal); err != nil {
+		return fmt.Errorf("outbox columns: %w", err)
+	}
+	b.idCol = ordinal - 1
+	return nil
+}
+
+func (b *OutboxBinlog) acquire(ctx context.Context) error {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `/* outbox_cdc.lock */ SELECT GET_LOCK(?, 0)`, b.lockName()).Scan(&got); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if got.Int64 != 1 {
+		_ = conn.Close()
+		return ErrBinlogLocked
+	}
+	b.lock = conn
+	return nil
+}
+
+func (b *OutboxBinlog) lockName() string {
+	return "outbox_relay:" + b.cfg.Name
+}
+
+func (b *OutboxBinlog) startPosition(ctx context.Context) (BinlogPosition, error) {
+	var pos BinlogPosition
+	err := b.db.QueryRowContext(ctx,
+		`/* outbox_cdc.load_position */ SELECT binlog_file, binlog_pos FROM outbox_relay_position WHERE name = ?`, b.cfg.Name,
+	).Scan(&pos.File, &pos.Pos)
+	if err == nil {
+		return pos, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return pos, err
+	}
+
+	// 第一次启动：从当前位置开始，之前插入的行由调用方先用轮询补发
+	for _, q := range []string{"SHOW MASTER STATUS", "SHOW BINARY LOG STATUS"} { // 8.4 起只有后者
+		pos, err = showStatus(ctx, b.db, q)
+		if err == nil {
+			return pos, nil
+		}
+	}
+	return pos, fmt.Errorf("%w: %v", ErrBinlogUnavailable, err)
+}
+
+// showStatus 只取前两列（File, Position），其余列随版本不同
+func showStatus(ctx context.Context, db *sql.DB, q string) (BinlogPosition, error) {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return BinlogPosition{}, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return BinlogPosition{}, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return BinlogPosition{}, err
+		}
+		return BinlogPosition{}, errors.New("binary logging is disabled")
+	}
+	var pos BinlogPosition
+	dest := make([]any, len(cols))
+	dest[0], dest[1] = &pos.File, &pos.Pos
+	for i := 2; i < len(dest); i++ {
+		dest[i] = new(sql.RawBytes)
+	}
+	return pos, rows.Scan(dest...)
+}
+
+// Next 阻塞到下一个事务提交，返回其中插入 outbox 的 id（可能为空）和该事务结束后的位点
+// ctx 超时不会丢失已读到一半的事务，下次调用接着读
+func (b *OutboxBinlog) Next(ctx context.Context) ([]uint64, BinlogPosition, error) {
+	for {
+		ev, err := b.streamer.GetEvent(ctx)
+		if err != nil {
+			return nil, BinlogPosition{}, err
+		}
+
+		switch e := ev.Event.(type) {
+		case *replication.RotateEvent:
+			b.file = string(e.NextLogName)
+		case *replication.RowsEvent:
+			if e.Type() != replication.EnumRowsEventTypeInsert ||
+				string(e.Table.Table) != "outbox" || string(e.Table.Schema) != b.schema {
+				continue
+			}
+			for _, row := range e.Rows {
+				id, err := rowID(row, b.idCol)
+				if err != nil {
+					return nil, BinlogPosition{}, err
+				}
+				b.txIDs = append(b.txIDs, id)
+			}
+		case *replication.XIDEvent:
+			ids := b.txIDs
+			b.txIDs = nil
+			return ids, BinlogPosition{
+				File: b.file,
+				Pos:  ev.Header.LogPos,
+				Time: time.Unix(int64(ev.Header.Timestamp), 0),
+			}, nil
+		}
+	}
+}
+
+// rowID：BIGINT UNSIGNED 在没有列元数据时按有符号解码
+func rowID(row []any, col int) (uint64, error) {
+	if col >= len(row) {
+		return 0, fmt.Errorf("outbox row has %d columns, id at %d", len(row), col)
+	}
+	switch v := row[col].(type) {
+	case int64:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("outbox id: unexpected %T (binlog_row_image must include id)", v)
+	}
+}
+
+// Load 读取仍为 pending 的行：已经被轮询或上一次运行发出的行直接跳过；
+// 同 key 有更早的 pending 行不在 ids 里时也跳过，由轮询按顺序补发
+func (b *OutboxBinlog) Load(ctx context.Context, ids []uint64) ([]event.OutboxRecord, error) {
+	return loadPending(ctx, b.db, ids)
+}
+
+// Save 持久化位点；调用方必须在这之前的消息都已发布并标记后才调用
+func (b *OutboxBinlog) Save(ctx context.Context, pos BinlogPosition) error {
+	const q = `/* outbox_cdc.save_position */
+INSERT INTO outbox_relay_position (name, binlog_file, binlog_pos) VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE binlog_file = VALUES(binlog_file), binlog_pos = VALUES(binlog_pos)`
+	_, err := b.db.ExecContext(ctx, q, b.cfg.Name, pos.File, pos.Pos)
+	return err
+}
+
+// Close 停止订阅并释放中继锁，之后可以重新 Start
+func (b *OutboxBinlog) Close() error {
+	if b.syncer != nil {
+		b.syncer.Close()
+		b.syncer, b.streamer = nil, nil
+	}
+	if b.lock == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = b.lock.ExecContext(ctx, `/* outbox_cdc.unlock */ DO RELEASE_LOCK(?)`, b.lockName())
+	err := b.lock.Close()
+	b.lock = nil
+	return err
+}
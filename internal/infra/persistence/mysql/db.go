@@ -3,9 +3,12 @@ package mysql
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	driver "github.com/go-sql-driver/mysql"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlmetrics"
 )
 
 type Config struct {
@@ -13,13 +16,25 @@ type Config struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
+
+	// 每条语句都会记 metrics；超过 SlowQuery 的额外打日志（Logger 为 nil 时只计数）
+	SlowQuery time.Duration
+	Logger    *slog.Logger
 }
 
 func Open(cfg Config) (*sql.DB, error) {
-	db, err := sql.Open("mysql", cfg.DSN)
+	dc, err := driver.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("sql open: %w", err)
+	}
+	connector, err := driver.NewConnector(dc)
 	if err != nil {
 		return nil, fmt.Errorf("sql open: %w", err)
 	}
+	db := sql.OpenDB(sqlmetrics.Wrap(connector, sqlmetrics.Options{
+		SlowQuery: cfg.SlowQuery,
+		Logger:    cfg.Logger,
+	}))
 
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
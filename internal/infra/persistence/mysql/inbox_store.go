@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type InboxStore struct {
+	db *sql.DB
+}
+
+func NewInboxStore(db *sql.DB) *InboxStore {
+	return &InboxStore{db: db}
+}
+
+// Record 要求在事务里：并发的重复消息会等先到者提交，然后被主键挡住
+func (s *InboxStore) Record(ctx context.Context, consumer, eventID string) (bool, error) {
+	ex := getExecer(s.db, ctx)
+	const q = `/* inbox.record */ INSERT IGNORE INTO inbox (consumer, event_id) VALUES (?, ?)`
+	res, err := ex.ExecContext(ctx, q, consumer, eventID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *InboxStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const q = `/* inbox.purge */ DELETE FROM inbox WHERE processed_at < ? ORDER BY processed_at LIMIT ?`
+	res, err := s.db.ExecContext(ctx, q, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
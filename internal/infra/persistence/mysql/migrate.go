@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// 迁移锁最多等这么久（秒），拿不到说明别的副本正在迁移
+const migrationLockTimeoutSec = 60
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	ms, err := migrate.Load(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, dialect{}, ms), nil
+}
+
+type dialect struct{}
+
+func (dialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	var ok sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK('schema_migrations', ?)`, migrationLockTimeoutSec).Scan(&ok); err != nil {
+		return err
+	}
+	if ok.Int64 != 1 {
+		return fmt.Errorf("timeout waiting for lock after %ds", migrationLockTimeoutSec)
+	}
+	return nil
+}
+
+func (dialect) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK('schema_migrations')`)
+	return err
+}
+
+func (dialect) CreateTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT UNSIGNED NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  applied_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+}
+
+func (dialect) Placeholder(int) string { return "?" }
+
+// MySQL 的 DDL 会隐式提交，没法放进事务
+func (dialect) TransactionalDDL() bool { return false }
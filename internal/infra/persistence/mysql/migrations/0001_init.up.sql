@@ -1,4 +1,4 @@
--- DB 已由 MYSQL_DATABASE 创建，这里只建表
+-- 初始表结构（与旧的 docs/sql/mysql-init 一致，已存在的库执行也是安全的）
 CREATE TABLE IF NOT EXISTS users (
   id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
   name VARCHAR(64) NOT NULL,
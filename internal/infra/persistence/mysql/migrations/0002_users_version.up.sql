@@ -0,0 +1,11 @@
+-- 用户版本号：每次变更 +1，缓存与事件按版本判断新旧
+-- MySQL 5.7 没有 ADD COLUMN IF NOT EXISTS，用 information_schema 判断，兼容旧的 docker 初始化脚本已加过该列的库
+SET @ddl = IF(
+  (SELECT COUNT(*) FROM information_schema.COLUMNS
+   WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'version') = 0,
+  'ALTER TABLE users ADD COLUMN version BIGINT UNSIGNED NOT NULL DEFAULT 1 AFTER email',
+  'DO 0'
+);
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,4 @@
+-- outbox 租约：多个 dispatcher 副本分摊投递，持有者崩溃后租约过期可被重新领取
+ALTER TABLE outbox
+  ADD COLUMN claimed_by VARCHAR(128) NULL AFTER headers,
+  ADD COLUMN claimed_until DATETIME(3) NULL AFTER claimed_by;
@@ -0,0 +1,7 @@
+ALTER TABLE outbox
+  DROP KEY idx_outbox_key_id,
+  DROP KEY idx_outbox_status_id,
+  DROP COLUMN next_attempt_at,
+  DROP COLUMN last_error,
+  DROP COLUMN attempts,
+  DROP COLUMN status;
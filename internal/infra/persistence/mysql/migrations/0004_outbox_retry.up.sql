@@ -0,0 +1,10 @@
+-- outbox 逐行重试状态：status = pending / sent / dead（多次失败后的终态，需人工处理）
+ALTER TABLE outbox
+  ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'pending' AFTER headers,
+  ADD COLUMN attempts INT UNSIGNED NOT NULL DEFAULT 0 AFTER status,
+  ADD COLUMN last_error VARCHAR(1024) NULL AFTER attempts,
+  ADD COLUMN next_attempt_at DATETIME(3) NULL AFTER last_error,
+  ADD KEY idx_outbox_status_id (status, id),
+  ADD KEY idx_outbox_key_id (msg_key, id);
+
+UPDATE outbox SET status = 'sent' WHERE sent_at IS NOT NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS outbox_relay_position;
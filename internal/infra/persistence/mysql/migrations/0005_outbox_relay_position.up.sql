@@ -0,0 +1,8 @@
+-- binlog CDC 中继的消费位点：Kafka 发布并标记 sent 之后才推进，重启从这里继续
+CREATE TABLE IF NOT EXISTS outbox_relay_position (
+  name VARCHAR(64) NOT NULL,
+  binlog_file VARCHAR(255) NOT NULL,
+  binlog_pos INT UNSIGNED NOT NULL,
+  updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
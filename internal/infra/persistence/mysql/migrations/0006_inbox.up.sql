@@ -0,0 +1,8 @@
+-- 消费端 inbox：(consumer, event_id) 去重，和处理的副作用在同一个事务里写入
+CREATE TABLE IF NOT EXISTS inbox (
+  consumer VARCHAR(64) NOT NULL,
+  event_id VARCHAR(128) NOT NULL,
+  processed_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (consumer, event_id),
+  KEY idx_inbox_processed_at (processed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+-- 审计记录保存 payload 的 schema 版本，回放时据此升级到最新版本；空表示 v1
+ALTER TABLE audit_logs
+  ADD COLUMN data_schema VARCHAR(255) NOT NULL DEFAULT '' AFTER event_key;
@@ -0,0 +1,4 @@
+-- users / outbox 的主键改由应用侧 Snowflake 生成（internal/pkg/idgen），去掉 AUTO_INCREMENT：
+-- 自增计数器会跟着跳到最大的 Snowflake ID 之后，漏传 ID 的写入拿到的值可能和之后发出的 ID 冲突
+ALTER TABLE users MODIFY id BIGINT UNSIGNED NOT NULL;
+ALTER TABLE outbox MODIFY id BIGINT UNSIGNED NOT NULL;
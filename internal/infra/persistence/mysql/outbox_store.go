@@ -4,20 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
 )
 
-type OutboxRow struct {
-	ID      uint64
-	Topic   string
-	MsgKey  string
-	Type    string
-	Payload []byte
-	Headers []byte
-}
-
 type OutboxStore struct {
 	db *sql.DB
 }
@@ -27,42 +23,203 @@ func NewOutboxStore(db *sql.DB) *OutboxStore {
 }
 
 // 给 app 用：写入 outbox（要求在事务里）
+// id 必须由应用侧生成：outbox.id 没有 AUTO_INCREMENT（见 0008 迁移）
 func (s *OutboxStore) Add(ctx context.Context, m event.OutboxMessage) error {
+	if m.ID == 0 {
+		return errors.New("mysql: outbox message id is required")
+	}
 	ex := getExecer(s.db, ctx)
 
 	payload, err := json.Marshal(m.Payload)
 	if err != nil {
 		return err
 	}
-	headers, err := json.Marshal(m.Headers)
+	// subject / dataschema 随 header 一起存，投递时还原成 CloudEvents 属性
+	headers, err := json.Marshal(cloudevents.OutboxHeaders(m))
 	if err != nil {
 		return err
 	}
 
-	const q = `INSERT INTO outbox (topic, msg_key, event_type, payload, headers) VALUES (?, ?, ?, ?, ?)`
-	_, err = ex.ExecContext(ctx, q, m.Topic, m.Key, m.Type, payload, headers)
+	const q = `/* outbox.add */ INSERT INTO outbox (id, topic, msg_key, event_type, payload, headers) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = ex.ExecContext(ctx, q, m.ID, m.Topic, m.Key, m.Type, payload, headers)
 	return err
 }
 
-// 给 worker 用：拉取未发送
-func (s *OutboxStore) ListUnsent(ctx context.Context, limit int) ([]OutboxRow, error) {
-	const q = `
-SELECT id, topic, msg_key, event_type, payload, COALESCE(headers, 'null')
+// 给 worker 用：领取一批未发送的消息
+// SKIP LOCKED（MySQL 8.0）：并发的 Claim 直接跳过别人正在领取的行，互不等待；租约保证发布期间不被重复领取
+func (s *OutboxStore) Claim(ctx context.Context, owner string, lease time.Duration, limit int) (_ []event.OutboxRecord, err error) {
+	sqlTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = sqlTx.Rollback()
+		}
+	}()
+
+	// 同 key 有更早的行在退避、或被别人持有时跳过，保证按 key 有序；空 key 不参与排序约束
+	const pick = `/* outbox.claim_pick */
+SELECT o.id FROM outbox o
+WHERE o.status = 'pending'
+  AND (o.next_attempt_at IS NULL OR o.next_attempt_at <= NOW(3))
+  AND (o.claimed_until IS NULL OR o.claimed_until < NOW(3) OR o.claimed_by = ?)
+  AND (o.msg_key = '' OR NOT EXISTS (
+    SELECT 1 FROM outbox p
+    WHERE p.msg_key = o.msg_key AND p.status = 'pending' AND p.id < o.id
+      AND (p.next_attempt_at > NOW(3) OR (p.claimed_until >= NOW(3) AND p.claimed_by <> ?))
+  ))
+ORDER BY o.id
+LIMIT ?
+FOR UPDATE SKIP LOCKED`
+
+	ids, err := queryIDs(ctx, sqlTx, pick, owner, owner, limit)
+	if err == nil && len(ids) > 0 {
+		ids, err = dropSkipped(ctx, sqlTx, ids)
+	}
+	if err != nil || len(ids) == 0 {
+		if err == nil {
+			err = sqlTx.Commit()
+		}
+		return nil, err
+	}
+
+	in, args := inClause(ids)
+	claim := `/* outbox.claim */
+UPDATE outbox SET claimed_by = ?, claimed_until = DATE_ADD(NOW(3), INTERVAL ? MICROSECOND)
+WHERE id IN (` + in + `)`
+	if _, err := sqlTx.ExecContext(ctx, claim, append([]any{owner, lease.Microseconds()}, args...)...); err != nil {
+		return nil, err
+	}
+
+	load := `/* outbox.claim_load */
+SELECT id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'), attempts, created_at
 FROM outbox
-WHERE sent_at IS NULL
+WHERE id IN (` + in + `)
+ORDER BY id`
+	rows, err := sqlTx.QueryContext(ctx, load, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []event.OutboxRecord
+	for rows.Next() {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.Attempts, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, sqlTx.Commit()
+}
+
+// dropSkipped 去掉同 key 有更早的 pending 行没被选中的行：那条行被 SKIP LOCKED 跳过了，
+// 正被并发的 Claim 领取，后面的行要等它（其他原因不可领取的更早行在 pick 里已经排除）
+func dropSkipped(ctx context.Context, tx *sql.Tx, ids []uint64) ([]uint64, error) {
+	in, args := inClause(ids)
+	q := `/* outbox.claim_skipped */
+SELECT o.id FROM outbox o
+WHERE o.id IN (` + in + `) AND o.msg_key <> '' AND EXISTS (
+  SELECT 1 FROM outbox p
+  WHERE p.msg_key = o.msg_key AND p.status = 'pending' AND p.id < o.id AND p.id NOT IN (` + in + `)
+)`
+	skipped, err := queryIDs(ctx, tx, q, append(args, args...)...)
+	if err != nil || len(skipped) == 0 {
+		return ids, err
+	}
+	return slices.DeleteFunc(ids, func(id uint64) bool { return slices.Contains(skipped, id) }), nil
+}
+
+// MarkSent 一条 UPDATE 标记整批
+func (s *OutboxStore) MarkSent(ctx context.Context, owner string, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	in, args := inClause(ids)
+	q := `/* outbox.mark_sent */ UPDATE outbox SET status = 'sent', sent_at = ?
+WHERE status = 'pending' AND COALESCE(claimed_by, '') = ? AND id IN (` + in + `)`
+	res, err := s.db.ExecContext(ctx, q, append([]any{time.Now(), owner}, args...)...)
+	return checkMarked(res, err, len(ids))
+}
+
+func (s *OutboxStore) MarkFailed(ctx context.Context, owner string, id uint64, lastErr string, retryIn time.Duration, dead bool) error {
+	const q = `/* outbox.mark_failed */
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = ?,
+    next_attempt_at = DATE_ADD(NOW(3), INTERVAL ? MICROSECOND),
+    status = IF(?, 'dead', status),
+    claimed_by = NULL, claimed_until = NULL
+WHERE id = ? AND status = 'pending' AND COALESCE(claimed_by, '') = ?`
+	res, err := s.db.ExecContext(ctx, q, lastErr, retryIn.Microseconds(), dead, id, owner)
+	return checkMarked(res, err, 1)
+}
+
+// checkMarked：改到的行数少于 want 说明有行已经不在自己名下
+func checkMarked(res sql.Result, err error, want int) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n < int64(want) {
+		return fmt.Errorf("%w: marked %d of %d rows", event.ErrLeaseLost, n, want)
+	}
+	return nil
+}
+
+func queryIDs(ctx context.Context, q execer, query string, args ...any) ([]uint64, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// inClause 生成 "?, ?, ?" 和对应参数
+func inClause(ids []uint64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// 给清理任务用：列出 before 之前已发送的行（走 (sent_at, id) 索引）
+func (s *OutboxStore) ListSentBefore(ctx context.Context, before time.Time, limit int) ([]event.OutboxRecord, error) {
+	const q = `/* outbox.list_sent_before */
+SELECT id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'), created_at, sent_at
+FROM outbox
+WHERE sent_at IS NOT NULL AND sent_at < ?
 ORDER BY id
 LIMIT ?`
 
-	rows, err := s.db.QueryContext(ctx, q, limit)
+	rows, err := s.db.QueryContext(ctx, q, before, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var res []OutboxRow
+	var res []event.OutboxRecord
 	for rows.Next() {
-		var r OutboxRow
-		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers); err != nil {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.CreatedAt, &r.SentAt); err != nil {
 			return nil, err
 		}
 		res = append(res, r)
@@ -70,8 +227,62 @@ LIMIT ?`
 	return res, rows.Err()
 }
 
-func (s *OutboxStore) MarkSent(ctx context.Context, id uint64) error {
-	const q = `UPDATE outbox SET sent_at = ? WHERE id = ? AND sent_at IS NULL`
-	_, err := s.db.ExecContext(ctx, q, time.Now(), id)
-	return err
+func (s *OutboxStore) Delete(ctx context.Context, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	in, args := inClause(ids)
+	res, err := s.db.ExecContext(ctx, `/* outbox.delete */ DELETE FROM outbox WHERE sent_at IS NOT NULL AND id IN (`+in+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Stats：information_schema 里的估算值，不做 COUNT(*) 全表扫描
+func (s *OutboxStore) Stats(ctx context.Context) (event.OutboxStats, error) {
+	const q = `/* outbox.stats */
+SELECT COALESCE(TABLE_ROWS, 0), COALESCE(DATA_LENGTH + INDEX_LENGTH, 0),
+  (SELECT COUNT(*) FROM outbox WHERE status = 'dead')
+FROM information_schema.TABLES
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'outbox'`
+
+	var st event.OutboxStats
+	err := s.db.QueryRowContext(ctx, q).Scan(&st.Rows, &st.Bytes, &st.Dead)
+	return st, err
+}
+
+// loadPending 按 id 读取仍为 pending、可以由中继直接发布的行（给 binlog 中继用）
+func loadPending(ctx context.Context, db *sql.DB, ids []uint64) ([]event.OutboxRecord, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	in, args := inClause(ids)
+	// 同 key 有更早的 pending 行不在这批里（失败退避中、交给了轮询、位点之前遗留的）时跳过，
+	// 留给轮询排在它后面按顺序补发
+	q := `/* outbox.load_pending */
+SELECT o.id, o.topic, o.msg_key, o.event_type, o.payload, COALESCE(o.headers, 'null'), o.attempts, o.created_at
+FROM outbox o
+WHERE o.status = 'pending' AND o.id IN (` + in + `)
+  AND (o.msg_key = '' OR NOT EXISTS (
+    SELECT 1 FROM outbox p
+    WHERE p.msg_key = o.msg_key AND p.status = 'pending' AND p.id < o.id AND p.id NOT IN (` + in + `)
+  ))
+ORDER BY o.id`
+
+	rows, err := db.QueryContext(ctx, q, append(args, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []event.OutboxRecord
+	for rows.Next() {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.Attempts, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
 }
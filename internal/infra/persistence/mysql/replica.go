@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+type ReplicaConfig struct {
+	DSNs          []string
+	MaxLag        time.Duration // 复制延迟超过该值摘除，恢复后自动加回
+	CheckInterval time.Duration
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaSet：只读副本集合，后台定期检查连通性和复制延迟，读请求在健康副本间轮询
+type ReplicaSet struct {
+	replicas []*replica
+	maxLag   time.Duration
+	next     atomic.Uint64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// OpenReplicas 连接池参数沿用主库配置；没有配置副本时返回 nil
+func OpenReplicas(base Config, rc ReplicaConfig) (*ReplicaSet, error) {
+	if len(rc.DSNs) == 0 {
+		return nil, nil
+	}
+
+	rs := &ReplicaSet{maxLag: rc.MaxLag, stop: make(chan struct{})}
+	for i, dsn := range rc.DSNs {
+		cfg := base
+		cfg.DSN = dsn
+		db, err := Open(cfg)
+		if err != nil {
+			_ = rs.Close()
+			return nil, fmt.Errorf("replica %d: %w", i, err)
+		}
+		rs.replicas = append(rs.replicas, &replica{db: db})
+	}
+
+	interval := rc.CheckInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	rs.checkAll()
+	rs.wg.Add(1)
+	go rs.loop(interval)
+
+	return rs, nil
+}
+
+// Pick 轮询选一个健康副本；全部不健康时返回 nil（调用方回退主库）
+func (rs *ReplicaSet) Pick() *sql.DB {
+	if rs == nil {
+		return nil
+	}
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := rs.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+func (rs *ReplicaSet) Close() error {
+	if rs.stop != nil {
+		select {
+		case <-rs.stop:
+		default:
+			close(rs.stop)
+		}
+	}
+	rs.wg.Wait()
+
+	var firstErr error
+	for _, r := range rs.replicas {
+		if err := r.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (rs *ReplicaSet) loop(interval time.Duration) {
+	defer rs.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.checkAll()
+		}
+	}
+}
+
+func (rs *ReplicaSet) checkAll() {
+	var healthy int64
+	for _, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		ok := rs.check(ctx, r.db)
+		cancel()
+
+		r.healthy.Store(ok)
+		if ok {
+			healthy++
+		}
+	}
+	metrics.DBReplicasHealthy.Set(healthy)
+}
+
+func (rs *ReplicaSet) check(ctx context.Context, db *sql.DB) bool {
+	if err := db.PingContext(ctx); err != nil {
+		metrics.DBReplicaCheckErrorsTotal.Add(1)
+		return false
+	}
+	if rs.maxLag <= 0 {
+		return true
+	}
+
+	lag, err := replicationLag(ctx, db)
+	if err != nil {
+		// 查不到延迟（权限不足/复制中断）按不健康处理，宁可回主库
+		metrics.DBReplicaCheckErrorsTotal.Add(1)
+		return false
+	}
+	return lag <= rs.maxLag
+}
+
+// replicationLag 读复制延迟：8.0.22 起是 SHOW REPLICA STATUS / Seconds_Behind_Source，
+// 8.4 起旧语法被移除；5.7 只有 SHOW SLAVE STATUS / Seconds_Behind_Master
+// 没有返回行说明不是副本（比如本地把主库当副本配置），延迟按 0 处理
+func replicationLag(ctx context.Context, db *sql.DB) (lag time.Duration, err error) {
+	for _, q := range []string{"SHOW REPLICA STATUS", "SHOW SLAVE STATUS"} {
+		lag, err = showReplicaLag(ctx, db, q)
+		if err == nil {
+			return lag, nil
+		}
+	}
+	return 0, err
+}
+
+func showReplicaLag(ctx context.Context, db *sql.DB, q string) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	vals := make([]sql.RawBytes, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return 0, err
+	}
+
+	for i, c := range cols {
+		if c != "Seconds_Behind_Source" && c != "Seconds_Behind_Master" {
+			continue
+		}
+		if vals[i] == nil {
+			return 0, fmt.Errorf("replication not running")
+		}
+		var sec int64
+		if _, err := fmt.Sscanf(string(vals[i]), "%d", &sec); err != nil {
+			return 0, err
+		}
+		return time.Duration(sec) * time.Second, nil
+	}
+	return 0, fmt.Errorf("Seconds_Behind_Source / Seconds_Behind_Master not found")
+}
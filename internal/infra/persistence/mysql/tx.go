@@ -3,31 +3,25 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
+
+	driver "github.com/go-sql-driver/mysql"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/consistency"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
 )
 
-type txKey struct{}
+type Transactor = sqltx.Transactor
 
-type Transactor struct {
-	db *sql.DB
+func NewTransactor(db *sql.DB, retry sqltx.RetryConfig) *Transactor {
+	return sqltx.New(db, retry, isRetryable)
 }
 
-func NewTransactor(db *sql.DB) *Transactor {
-	return &Transactor{db: db}
-}
-
-func (t *Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
-	tx, err := t.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-
-	ctxWithTx := context.WithValue(ctx, txKey{}, tx)
-
-	if err := fn(ctxWithTx); err != nil {
-		_ = tx.Rollback()
-		return err
-	}
-	return tx.Commit()
+// 1213 死锁、1205 锁等待超时：事务已被回滚（或应回滚），整体重跑是安全的
+func isRetryable(err error) bool {
+	var me *driver.MySQLError
+	return errors.As(err, &me) && (me.Number == 1213 || me.Number == 1205)
 }
 
 // 内部给 repo / outbox 取执行器
@@ -38,10 +32,23 @@ type execer interface {
 }
 
 func getExecer(db *sql.DB, ctx context.Context) execer {
-	if v := ctx.Value(txKey{}); v != nil {
-		if tx, ok := v.(*sql.Tx); ok {
-			return tx
+	if tx, ok := sqltx.From(ctx, db); ok {
+		return tx
+	}
+	return db
+}
+
+// 读操作取执行器：事务内走事务；要求 read-your-writes 时走主库；否则优先走健康副本
+func getReader(db *sql.DB, replicas *ReplicaSet, ctx context.Context) execer {
+	if tx, ok := sqltx.From(ctx, db); ok {
+		return tx
+	}
+	if !consistency.IsStrong(ctx) {
+		if r := replicas.Pick(); r != nil {
+			metrics.DBReplicaReadsTotal.Add(1)
+			return r
 		}
 	}
+	metrics.DBPrimaryReadsTotal.Add(1)
 	return db
 }
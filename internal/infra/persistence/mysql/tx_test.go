@@ -0,0 +1,30 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	driver "github.com/go-sql-driver/mysql"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"deadlock", &driver.MySQLError{Number: 1213}, true},
+		{"lock wait timeout", &driver.MySQLError{Number: 1205}, true},
+		{"wrapped deadlock", fmt.Errorf("create user: %w", &driver.MySQLError{Number: 1213}), true},
+		{"duplicate key", &driver.MySQLError{Number: 1062}, false},
+		{"bad connection", driver.ErrInvalidConn, false},
+		{"not a driver error", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -4,49 +4,49 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 
 	driver "github.com/go-sql-driver/mysql"
 
 	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/consistency"
 )
 
 type UserRepo struct {
-	db *sql.DB
+	db       *sql.DB
+	replicas *ReplicaSet // 可为 nil：全部读主库
 }
 
-func NewUserRepo(db *sql.DB) *UserRepo {
-	return &UserRepo{db: db}
+func NewUserRepo(db *sql.DB, replicas *ReplicaSet) *UserRepo {
+	return &UserRepo{db: db, replicas: replicas}
 }
 
-func (r *UserRepo) Create(ctx context.Context, name, email string) (user.User, error) {
+func (r *UserRepo) Create(ctx context.Context, id uint64, name, email string) (user.User, error) {
 	ex := getExecer(r.db, ctx)
 
-	const q = `INSERT INTO users (name, email) VALUES (?, ?)`
+	const q = `/* user.create */ INSERT INTO users (id, name, email) VALUES (?, ?, ?)`
 
-	res, err := ex.ExecContext(ctx, q, name, email)
+	_, err := ex.ExecContext(ctx, q, id, name, email)
 	if err != nil {
 		var me *driver.MySQLError
-		if errors.As(err, &me) && me.Number == 1062 {
+		// 只有邮箱唯一键冲突才是业务错误；主键冲突说明发号重复（比如多个副本用了同一个节点号），原样返回
+		if errors.As(err, &me) && me.Number == 1062 && strings.Contains(me.Message, "uk_users_email") {
 			return user.User{}, user.ErrEmailExists
 		}
 		return user.User{}, err
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		return user.User{}, err
-	}
-
-	return r.GetByID(ctx, uint64(id))
+	// 刚写入的数据：强制读主库（version / created_at 由数据库填默认值）
+	return r.GetByID(consistency.Strong(ctx), id)
 }
 
 func (r *UserRepo) GetByID(ctx context.Context, id uint64) (user.User, error) {
-	ex := getExecer(r.db, ctx)
+	ex := getReader(r.db, r.replicas, ctx)
 
-	const q = `SELECT id, name, email, created_at FROM users WHERE id = ? LIMIT 1`
+	const q = `/* user.get_by_id */ SELECT id, name, email, version, created_at FROM users WHERE id = ? LIMIT 1`
 
 	var u user.User
-	err := ex.QueryRowContext(ctx, q, id).Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+	err := ex.QueryRowContext(ctx, q, id).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user.User{}, user.ErrNotFound
@@ -58,12 +58,12 @@ func (r *UserRepo) GetByID(ctx context.Context, id uint64) (user.User, error) {
 }
 
 func (r *UserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
-	ex := getExecer(r.db, ctx)
+	ex := getReader(r.db, r.replicas, ctx)
 
-	const q = `SELECT id, name, email, created_at FROM users WHERE email = ? LIMIT 1`
+	const q = `/* user.get_by_email */ SELECT id, name, email, version, created_at FROM users WHERE email = ? LIMIT 1`
 
 	var u user.User
-	err := ex.QueryRowContext(ctx, q, email).Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+	err := ex.QueryRowContext(ctx, q, email).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user.User{}, user.ErrNotFound
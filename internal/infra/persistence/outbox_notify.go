@@ -0,0 +1,26 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/hacker4257/go-ddd-template/internal/app/tx"
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+)
+
+// NotifyOnCommit：写入 outbox 的事务提交后通知 dispatcher；回滚则不通知
+func NotifyOnCommit(o event.Outbox, n event.OutboxNotifier) event.Outbox {
+	return notifyingOutbox{Outbox: o, n: n}
+}
+
+type notifyingOutbox struct {
+	event.Outbox
+	n event.OutboxNotifier
+}
+
+func (o notifyingOutbox) Add(ctx context.Context, m event.OutboxMessage) error {
+	if err := o.Outbox.Add(ctx, m); err != nil {
+		return err
+	}
+	tx.AfterCommit(ctx, o.n.Notify)
+	return nil
+}
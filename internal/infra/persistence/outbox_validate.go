@@ -0,0 +1,49 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/eventschema"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// ValidateOnAdd：写入 outbox 前按 schema 校验 payload，不合法（或未登记类型）的事件直接让事务失败，不会被投递出去
+// dataschema 为空时补上该类型的最新版本
+func ValidateOnAdd(o event.Outbox, reg *eventschema.Registry) event.Outbox {
+	return validatingOutbox{Outbox: o, reg: reg}
+}
+
+type validatingOutbox struct {
+	event.Outbox
+	reg *eventschema.Registry
+}
+
+func (o validatingOutbox) Add(ctx context.Context, m event.OutboxMessage) error {
+	def, err := o.resolve(m)
+	if err != nil {
+		metrics.EventsRejectedTotal.Add(1)
+		return err
+	}
+	b, err := json.Marshal(m.Payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s payload: %w", m.Type, err)
+	}
+	if err := def.Validate(b); err != nil {
+		metrics.EventsRejectedTotal.Add(1)
+		return err
+	}
+
+	m.Payload = json.RawMessage(b) // 存储时不再重复编码
+	m.DataSchema = def.URI()
+	return o.Outbox.Add(ctx, m)
+}
+
+func (o validatingOutbox) resolve(m event.OutboxMessage) (*eventschema.Definition, error) {
+	if m.DataSchema == "" {
+		return o.reg.Latest(m.Type)
+	}
+	return o.reg.Resolve(m.Type, m.DataSchema)
+}
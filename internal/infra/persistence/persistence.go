@@ -0,0 +1,135 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/app/tx"
+	"github.com/hacker4257/go-ddd-template/internal/domain/audit"
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/migrate"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/mysql"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/postgres"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlite"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqlmetrics"
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type Config struct {
+	Driver        string // mysql（默认）/ postgres / sqlite
+	MySQL         mysql.Config
+	MySQLReplicas mysql.ReplicaConfig // 可选：只读副本
+	Postgres      postgres.Config
+	SQLite        sqlite.Config
+	TxRetry       sqltx.RetryConfig // 死锁等可重试错误的自动重试
+	StatsInterval time.Duration     // 连接池状态导出到 metrics 的周期
+}
+
+// OutboxStore：server 写入 + worker 投递
+type OutboxStore interface {
+	event.Outbox
+	event.OutboxSource
+	event.OutboxRetention
+}
+
+// InboxStore：worker 消费去重 + 保留期清理
+type InboxStore interface {
+	event.Inbox
+	event.InboxRetention
+}
+
+// Store：按 driver 组装好的一整套端口实现
+type Store struct {
+	Driver     string
+	DB         *sql.DB
+	Transactor tx.Transactor
+	Users      user.Repo
+	Audit      audit.Repo
+	Outbox     OutboxStore
+	Inbox      InboxStore
+
+	newMigrator func(*sql.DB) (*migrate.Migrator, error)
+	closers     []func() error
+}
+
+func Open(cfg Config) (*Store, error) {
+	switch cfg.Driver {
+	case "", DriverMySQL:
+		db, err := mysql.Open(cfg.MySQL)
+		if err != nil {
+			return nil, err
+		}
+		replicas, err := mysql.OpenReplicas(cfg.MySQL, cfg.MySQLReplicas)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		st := &Store{
+			Driver:      DriverMySQL,
+			DB:          db,
+			Transactor:  mysql.NewTransactor(db, cfg.TxRetry),
+			Users:       mysql.NewUserRepo(db, replicas),
+			Audit:       mysql.NewAuditRepo(db),
+			Outbox:      mysql.NewOutboxStore(db),
+			Inbox:       mysql.NewInboxStore(db),
+			newMigrator: mysql.NewMigrator,
+		}
+		st.closers = append(st.closers, sqlmetrics.ExportStats(db, cfg.StatsInterval))
+		if replicas != nil {
+			st.closers = append(st.closers, replicas.Close)
+		}
+		return st, nil
+	case DriverPostgres:
+		db, err := postgres.Open(cfg.Postgres)
+		if err != nil {
+			return nil, err
+		}
+		return &Store{
+			Driver:      DriverPostgres,
+			DB:          db,
+			Transactor:  postgres.NewTransactor(db, cfg.TxRetry),
+			Users:       postgres.NewUserRepo(db),
+			Audit:       postgres.NewAuditRepo(db),
+			Outbox:      postgres.NewOutboxStore(db),
+			Inbox:       postgres.NewInboxStore(db),
+			newMigrator: postgres.NewMigrator,
+		}, nil
+	case DriverSQLite:
+		db, err := sqlite.Open(cfg.SQLite)
+		if err != nil {
+			return nil, err
+		}
+		return &Store{
+			Driver:      DriverSQLite,
+			DB:          db,
+			Transactor:  sqlite.NewTransactor(db, cfg.TxRetry),
+			Users:       sqlite.NewUserRepo(db),
+			Audit:       sqlite.NewAuditRepo(db),
+			Outbox:      sqlite.NewOutboxStore(db),
+			Inbox:       sqlite.NewInboxStore(db),
+			newMigrator: sqlite.NewMigrator,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown db driver %q", cfg.Driver)
+	}
+}
+
+// Migrator 返回对应 driver 的迁移器（migrations 各自内嵌在 driver 包里）
+func (s *Store) Migrator() (*migrate.Migrator, error) {
+	return s.newMigrator(s.DB)
+}
+
+func (s *Store) Close() error {
+	for _, c := range s.closers {
+		_ = c()
+	}
+	return s.DB.Close()
+}
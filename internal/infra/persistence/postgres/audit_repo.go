@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/audit"
+)
+
+type AuditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Insert(ctx context.Context, eventType, eventKey, dataSchema string, payload []byte) error {
+	ex := getExecer(r.db, ctx)
+	const q = `INSERT INTO audit_logs (event_type, event_key, data_schema, payload) VALUES ($1, $2, $3, $4)`
+	_, err := ex.ExecContext(ctx, q, eventType, eventKey, dataSchema, payload)
+	return err
+}
+
+func (r *AuditRepo) List(ctx context.Context, afterID uint64, eventType string, limit int) ([]audit.Log, error) {
+	const q = `
+SELECT id, event_type, event_key, data_schema, payload, created_at
+FROM audit_logs
+WHERE id > $1 AND ($2 = '' OR event_type = $2)
+ORDER BY id
+LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, q, afterID, eventType, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []audit.Log
+	for rows.Next() {
+		var l audit.Log
+		if err := rows.Scan(&l.ID, &l.EventType, &l.EventKey, &l.DataSchema, &l.Payload, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type InboxStore struct {
+	db *sql.DB
+}
+
+func NewInboxStore(db *sql.DB) *InboxStore {
+	return &InboxStore{db: db}
+}
+
+// Record 要求在事务里：并发的重复消息会等先到者提交，然后被主键挡住
+func (s *InboxStore) Record(ctx context.Context, consumer, eventID string) (bool, error) {
+	ex := getExecer(s.db, ctx)
+	const q = `INSERT INTO inbox (consumer, event_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	res, err := ex.ExecContext(ctx, q, consumer, eventID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Purge：DELETE 不支持 LIMIT，先按索引挑出一批主键
+func (s *InboxStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const q = `
+DELETE FROM inbox WHERE (consumer, event_id) IN (
+  SELECT consumer, event_id FROM inbox WHERE processed_at < $1 ORDER BY processed_at LIMIT $2
+)`
+	res, err := s.db.ExecContext(ctx, q, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
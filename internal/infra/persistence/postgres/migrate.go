@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// pg_advisory_lock 的 key（任意固定值，和业务锁不冲突即可）
+const migrationLockKey int64 = 72630001
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	ms, err := migrate.Load(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, dialect{}, ms), nil
+}
+
+type dialect struct{}
+
+func (dialect) Lock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey)
+	return err
+}
+
+func (dialect) Unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	return err
+}
+
+func (dialect) CreateTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT NOT NULL PRIMARY KEY,
+  name VARCHAR(128) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+}
+
+func (dialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// Postgres 的 DDL 可以放进事务，失败整体回滚
+func (dialect) TransactionalDDL() bool { return true }
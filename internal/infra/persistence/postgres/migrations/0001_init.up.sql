@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS users (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  name VARCHAR(64) NOT NULL,
+  email VARCHAR(128) NOT NULL,
+  version BIGINT NOT NULL DEFAULT 1,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  CONSTRAINT uk_users_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  topic VARCHAR(128) NOT NULL,
+  msg_key VARCHAR(128) NOT NULL DEFAULT '',
+  event_type VARCHAR(64) NOT NULL,
+  payload JSONB NOT NULL,
+  headers JSONB NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  sent_at TIMESTAMPTZ NULL
+);
+
+-- 只索引未发送的行，dispatcher 轮询用
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (id) WHERE sent_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  event_type VARCHAR(64) NOT NULL,
+  event_key VARCHAR(128) NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_event ON audit_logs (event_type, event_key);
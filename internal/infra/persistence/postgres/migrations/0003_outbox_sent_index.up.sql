@@ -0,0 +1,2 @@
+-- 清理任务按 sent_at 范围删除已发送的行
+CREATE INDEX IF NOT EXISTS idx_outbox_sent_at ON outbox (sent_at) WHERE sent_at IS NOT NULL;
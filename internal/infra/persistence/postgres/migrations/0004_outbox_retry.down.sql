@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_outbox_dead;
+DROP INDEX IF EXISTS idx_outbox_pending_key;
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (id) WHERE sent_at IS NULL;
+
+ALTER TABLE outbox
+  DROP COLUMN IF EXISTS next_attempt_at,
+  DROP COLUMN IF EXISTS last_error,
+  DROP COLUMN IF EXISTS attempts,
+  DROP COLUMN IF EXISTS status;
@@ -0,0 +1,13 @@
+-- outbox 逐行重试状态：status = pending / sent / dead（多次失败后的终态，需人工处理）
+ALTER TABLE outbox
+  ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'pending',
+  ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS last_error VARCHAR(1024) NULL,
+  ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NULL;
+
+UPDATE outbox SET status = 'sent' WHERE sent_at IS NOT NULL AND status = 'pending';
+
+DROP INDEX IF EXISTS idx_outbox_unsent;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_pending_key ON outbox (msg_key, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_dead ON outbox (id) WHERE status = 'dead';
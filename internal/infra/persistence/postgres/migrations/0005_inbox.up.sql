@@ -0,0 +1,9 @@
+-- 消费端 inbox：(consumer, event_id) 去重，和处理的副作用在同一个事务里写入
+CREATE TABLE IF NOT EXISTS inbox (
+  consumer VARCHAR(64) NOT NULL,
+  event_id VARCHAR(128) NOT NULL,
+  processed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (consumer, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inbox_processed_at ON inbox (processed_at);
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+)
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+func NewOutboxStore(db *sql.DB) *OutboxStore {
+	return &OutboxStore{db: db}
+}
+
+// 给 app 用：写入 outbox（要求在事务里）
+func (s *OutboxStore) Add(ctx context.Context, m event.OutboxMessage) error {
+	ex := getExecer(s.db, ctx)
+
+	payload, err := json.Marshal(m.Payload)
+	if err != nil {
+		return err
+	}
+	// subject / dataschema 随 header 一起存，投递时还原成 CloudEvents 属性
+	headers, err := json.Marshal(cloudevents.OutboxHeaders(m))
+	if err != nil {
+		return err
+	}
+
+	// id 为 0 时回退到 identity 序列
+	const q = `
+INSERT INTO outbox (id, topic, msg_key, event_type, payload, headers)
+VALUES (COALESCE(NULLIF($1::BIGINT, 0), nextval(pg_get_serial_sequence('outbox', 'id'))), $2, $3, $4, $5, $6)`
+	_, err = ex.ExecContext(ctx, q, int64(m.ID), m.Topic, m.Key, m.Type, payload, headers)
+	return err
+}
+
+// 给 worker 用：领取一批未发送的消息
+// SKIP LOCKED：并发的 Claim 直接跳过别人正在领取的行，互不等待；租约保证发布期间不被重复领取
+func (s *OutboxStore) Claim(ctx context.Context, owner string, lease time.Duration, limit int) ([]event.OutboxRecord, error) {
+	const q = `
+WITH picked AS (
+  SELECT o.id, o.msg_key FROM outbox o
+  WHERE o.status = 'pending'
+    AND (o.next_attempt_at IS NULL OR o.next_attempt_at <= now())
+    AND (o.claimed_until IS NULL OR o.claimed_until < now() OR o.claimed_by = $1)
+    -- 同 key 有更早的行在退避、或被别人持有时跳过，保证按 key 有序；空 key 不参与排序约束
+    AND (o.msg_key = '' OR NOT EXISTS (
+      SELECT 1 FROM outbox p
+      WHERE p.msg_key = o.msg_key AND p.status = 'pending' AND p.id < o.id
+        AND (p.next_attempt_at > now() OR (p.claimed_until >= now() AND p.claimed_by <> $1))
+    ))
+  ORDER BY o.id
+  LIMIT $3
+  FOR UPDATE OF o SKIP LOCKED
+)
+UPDATE outbox SET claimed_by = $1, claimed_until = now() + make_interval(secs => $2)
+WHERE id IN (
+  SELECT c.id FROM picked c
+  -- 同 key 更早的 pending 行没被选中，说明被 SKIP LOCKED 跳过、正被并发的 Claim 领取，后面的行要等它
+  WHERE c.msg_key = '' OR NOT EXISTS (
+    SELECT 1 FROM outbox p
+    WHERE p.msg_key = c.msg_key AND p.status = 'pending' AND p.id < c.id
+      AND p.id NOT IN (SELECT id FROM picked)
+  )
+)
+RETURNING id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'::jsonb), attempts, created_at`
+
+	rows, err := s.db.QueryContext(ctx, q, owner, lease.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []event.OutboxRecord
+	for rows.Next() {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.Attempts, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING 不保证顺序
+	slices.SortFunc(res, func(a, b event.OutboxRecord) int { return cmp.Compare(a.ID, b.ID) })
+	return res, nil
+}
+
+// MarkSent 一条 UPDATE 标记整批
+func (s *OutboxStore) MarkSent(ctx context.Context, owner string, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	const q = `UPDATE outbox SET status = 'sent', sent_at = $1 WHERE status = 'pending' AND COALESCE(claimed_by, '') = $2 AND id = ANY($3)`
+	res, err := s.db.ExecContext(ctx, q, time.Now(), owner, int64s(ids))
+	return checkMarked(res, err, len(ids))
+}
+
+func (s *OutboxStore) MarkFailed(ctx context.Context, owner string, id uint64, lastErr string, retryIn time.Duration, dead bool) error {
+	const q = `
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = $1,
+    next_attempt_at = now() + make_interval(secs => $2),
+    status = CASE WHEN $3 THEN 'dead' ELSE status END,
+    claimed_by = NULL, claimed_until = NULL
+WHERE id = $4 AND status = 'pending' AND COALESCE(claimed_by, '') = $5`
+	res, err := s.db.ExecContext(ctx, q, lastErr, retryIn.Seconds(), dead, int64(id), owner)
+	return checkMarked(res, err, 1)
+}
+
+// checkMarked：改到的行数少于 want 说明有行已经不在自己名下
+func checkMarked(res sql.Result, err error, want int) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n < int64(want) {
+		return fmt.Errorf("%w: marked %d of %d rows", event.ErrLeaseLost, n, want)
+	}
+	return nil
+}
+
+// 给清理任务用：列出 before 之前已发送的行
+func (s *OutboxStore) ListSentBefore(ctx context.Context, before time.Time, limit int) ([]event.OutboxRecord, error) {
+	const q = `
+SELECT id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'::jsonb), created_at, sent_at
+FROM outbox
+WHERE sent_at IS NOT NULL AND sent_at < $1
+ORDER BY id
+LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, q, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []event.OutboxRecord
+	for rows.Next() {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.CreatedAt, &r.SentAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+func (s *OutboxStore) Delete(ctx context.Context, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at IS NOT NULL AND id = ANY($1)`, int64s(ids))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Stats：reltuples 是 ANALYZE 时的估算值，不做 COUNT(*) 全表扫描
+func (s *OutboxStore) Stats(ctx context.Context) (event.OutboxStats, error) {
+	const q = `
+SELECT GREATEST(c.reltuples, 0)::BIGINT, pg_total_relation_size(c.oid),
+  (SELECT COUNT(*) FROM outbox WHERE status = 'dead')
+FROM pg_class c
+WHERE c.oid = to_regclass('outbox')`
+
+	var st event.OutboxStats
+	err := s.db.QueryRowContext(ctx, q).Scan(&st.Rows, &st.Bytes, &st.Dead)
+	return st, err
+}
+
+// pgx 没有 uint64 数组类型，按 BIGINT[] 传
+func int64s(ids []uint64) []int64 {
+	arr := make([]int64, len(ids))
+	for i, id := range ids {
+		arr[i] = int64(id)
+	}
+	return arr
+}
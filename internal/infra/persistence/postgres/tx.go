@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
+)
+
+type Transactor = sqltx.Transactor
+
+func NewTransactor(db *sql.DB, retry sqltx.RetryConfig) *Transactor {
+	return sqltx.New(db, retry, isRetryable)
+}
+
+// 40P01 deadlock_detected、40001 serialization_failure
+func isRetryable(err error) bool {
+	var pe *pgconn.PgError
+	return errors.As(err, &pe) && (pe.Code == "40P01" || pe.Code == "40001")
+}
+
+// 内部给 repo / outbox 取执行器
+type execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
+func getExecer(db *sql.DB, ctx context.Context) execer {
+	if tx, ok := sqltx.From(ctx, db); ok {
+		return tx
+	}
+	return db
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+)
+
+// SQLSTATE unique_violation
+const uniqueViolation = "23505"
+
+type UserRepo struct {
+	db *sql.DB
+}
+
+func NewUserRepo(db *sql.DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+func (r *UserRepo) Create(ctx context.Context, id uint64, name, email string) (user.User, error) {
+	ex := getExecer(r.db, ctx)
+
+	const q = `INSERT INTO users (id, name, email) VALUES ($1, $2, $3) RETURNING id, name, email, version, created_at`
+
+	var u user.User
+	err := ex.QueryRowContext(ctx, q, id, name, email).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
+	if err != nil {
+		var pe *pgconn.PgError
+		if errors.As(err, &pe) && pe.Code == uniqueViolation && pe.ConstraintName == "uk_users_email" {
+			return user.User{}, user.ErrEmailExists
+		}
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id uint64) (user.User, error) {
+	ex := getExecer(r.db, ctx)
+
+	const q = `SELECT id, name, email, version, created_at FROM users WHERE id = $1 LIMIT 1`
+
+	var u user.User
+	err := ex.QueryRowContext(ctx, q, id).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, user.ErrNotFound
+		}
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	ex := getExecer(r.db, ctx)
+
+	const q = `SELECT id, name, email, version, created_at FROM users WHERE email = $1 LIMIT 1`
+
+	var u user.User
+	err := ex.QueryRowContext(ctx, q, email).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, user.ErrNotFound
+		}
+		return user.User{}, err
+	}
+
+	return u, nil
+}
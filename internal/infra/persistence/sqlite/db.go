@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // 纯 Go 驱动，不需要 cgo
+)
+
+type Config struct {
+	// DSN 例：
+	//   file:go_ddd.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)
+	//   file::memory:?cache=shared
+	DSN          string
+	MaxOpenConns int
+}
+
+func Open(cfg Config) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("sql open: %w", err)
+	}
+
+	// 内存库每个连接都是独立的库，只能用一个连接
+	maxOpen := cfg.MaxOpenConns
+	if maxOpen <= 0 || strings.Contains(cfg.DSN, ":memory:") {
+		maxOpen = 1
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxOpen)
+	db.SetConnMaxLifetime(0) // 内存库连接关闭数据就没了
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("db ping: %w", err)
+	}
+
+	if _, err := db.Exec(`PRAGMA foreign_keys = ON`); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("sqlite pragma: %w", err)
+	}
+
+	return db, nil
+}
+
+// 时间统一写成 UTC 文本（和表默认值 strftime('%Y-%m-%d %H:%M:%f') 同格式），保证按字符串比较就是按时间比较
+const timeLayout = "2006-01-02 15:04:05.000"
+
+func now() string {
+	return at(time.Now())
+}
+
+func at(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
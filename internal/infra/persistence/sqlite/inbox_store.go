@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type InboxStore struct {
+	db *sql.DB
+}
+
+func NewInboxStore(db *sql.DB) *InboxStore {
+	return &InboxStore{db: db}
+}
+
+// Record 要求在事务里：写事务全库串行，重复消息被主键挡住
+func (s *InboxStore) Record(ctx context.Context, consumer, eventID string) (bool, error) {
+	ex := getExecer(s.db, ctx)
+	const q = `INSERT INTO inbox (consumer, event_id, processed_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`
+	res, err := ex.ExecContext(ctx, q, consumer, eventID, at(time.Now()))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *InboxStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const q = `
+DELETE FROM inbox WHERE rowid IN (
+  SELECT rowid FROM inbox WHERE processed_at < ? ORDER BY processed_at LIMIT ?
+)`
+	res, err := s.db.ExecContext(ctx, q, at(before), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	ms, err := migrate.Load(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, dialect{}, ms), nil
+}
+
+type dialect struct{}
+
+// SQLite 用于本地开发/测试，只有单进程访问；写事务本身由文件锁串行化
+func (dialect) Lock(context.Context, *sql.Conn) error   { return nil }
+func (dialect) Unlock(context.Context, *sql.Conn) error { return nil }
+
+func (dialect) CreateTableSQL() string {
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER NOT NULL PRIMARY KEY,
+  name TEXT NOT NULL,
+  checksum TEXT NOT NULL,
+  applied_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+)`
+}
+
+func (dialect) Placeholder(int) string { return "?" }
+
+func (dialect) TransactionalDDL() bool { return true }
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// openTestDB：每个测试一个独立的内存库（不带 cache=shared），已迁移到最新
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Open(Config{DSN: "file::memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(Config{DSN: "file::memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total == 0 {
+		t.Fatal("Up applied no migrations")
+	}
+	for _, table := range []string{"users", "outbox", "inbox", "audit_logs"} {
+		if !tableExists(t, db, table) {
+			t.Errorf("table %s missing after Up", table)
+		}
+	}
+	if n, err := m.Up(ctx); err != nil || n != 0 {
+		t.Fatalf("second Up = %d, %v; want 0, nil", n, err)
+	}
+
+	sts, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range sts {
+		if !st.Applied || st.Mismatch {
+			t.Errorf("migration %d %s: applied=%v mismatch=%v", st.Version, st.Name, st.Applied, st.Mismatch)
+		}
+	}
+
+	// 逐个回滚，每一步的 down 都要能执行
+	for i := total; i > 0; i-- {
+		if n, err := m.Down(ctx, 1); err != nil || n != 1 {
+			t.Fatalf("Down with %d applied = %d, %v", i, n, err)
+		}
+	}
+	for _, table := range []string{"users", "outbox", "inbox", "audit_logs"} {
+		if tableExists(t, db, table) {
+			t.Errorf("table %s still exists after Down", table)
+		}
+	}
+
+	// 回滚后可以重新迁移
+	if n, err := m.Up(ctx); err != nil || n != total {
+		t.Fatalf("Up after Down = %d, %v; want %d", n, err, total)
+	}
+}
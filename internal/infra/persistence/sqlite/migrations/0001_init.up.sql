@@ -0,0 +1,31 @@
+-- 时间统一存 UTC 文本（毫秒精度），驱动按 DATETIME 类型解析成 time.Time
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  email TEXT NOT NULL UNIQUE,
+  version INTEGER NOT NULL DEFAULT 1,
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id INTEGER PRIMARY KEY,
+  topic TEXT NOT NULL,
+  msg_key TEXT NOT NULL DEFAULT '',
+  event_type TEXT NOT NULL,
+  payload BLOB NOT NULL,
+  headers BLOB NULL,
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  sent_at DATETIME NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_sent_at_id ON outbox (sent_at, id);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+  id INTEGER PRIMARY KEY,
+  event_type TEXT NOT NULL,
+  event_key TEXT NOT NULL,
+  payload BLOB NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_event ON audit_logs (event_type, event_key);
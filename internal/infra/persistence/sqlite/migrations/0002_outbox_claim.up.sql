@@ -0,0 +1,3 @@
+-- outbox 租约：多个 dispatcher 副本分摊投递，持有者崩溃后租约过期可被重新领取
+ALTER TABLE outbox ADD COLUMN claimed_by TEXT NULL;
+ALTER TABLE outbox ADD COLUMN claimed_until DATETIME NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_outbox_key_id;
+DROP INDEX IF EXISTS idx_outbox_status_id;
+ALTER TABLE outbox DROP COLUMN next_attempt_at;
+ALTER TABLE outbox DROP COLUMN last_error;
+ALTER TABLE outbox DROP COLUMN attempts;
+ALTER TABLE outbox DROP COLUMN status;
@@ -0,0 +1,10 @@
+-- outbox 逐行重试状态：status = pending / sent / dead（多次失败后的终态，需人工处理）
+ALTER TABLE outbox ADD COLUMN status TEXT NOT NULL DEFAULT 'pending';
+ALTER TABLE outbox ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE outbox ADD COLUMN last_error TEXT NULL;
+ALTER TABLE outbox ADD COLUMN next_attempt_at DATETIME NULL;
+
+UPDATE outbox SET status = 'sent' WHERE sent_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_status_id ON outbox (status, id);
+CREATE INDEX IF NOT EXISTS idx_outbox_key_id ON outbox (msg_key, id);
@@ -0,0 +1,2 @@
+-- 审计记录保存 payload 的 schema 版本，回放时据此升级到最新版本；空表示 v1
+ALTER TABLE audit_logs ADD COLUMN data_schema TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,181 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+	"github.com/hacker4257/go-ddd-template/internal/infra/mq/cloudevents"
+)
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+func NewOutboxStore(db *sql.DB) *OutboxStore {
+	return &OutboxStore{db: db}
+}
+
+// 给 app 用：写入 outbox（要求在事务里）
+func (s *OutboxStore) Add(ctx context.Context, m event.OutboxMessage) error {
+	ex := getExecer(s.db, ctx)
+
+	payload, err := json.Marshal(m.Payload)
+	if err != nil {
+		return err
+	}
+	// subject / dataschema 随 header 一起存，投递时还原成 CloudEvents 属性
+	headers, err := json.Marshal(cloudevents.OutboxHeaders(m))
+	if err != nil {
+		return err
+	}
+
+	// INTEGER PRIMARY KEY 写 NULL 时自动分配
+	const q = `INSERT INTO outbox (id, topic, msg_key, event_type, payload, headers) VALUES (NULLIF(?, 0), ?, ?, ?, ?, ?)`
+	_, err = ex.ExecContext(ctx, q, int64(m.ID), m.Topic, m.Key, m.Type, payload, headers)
+	return err
+}
+
+// 给 worker 用：领取一批未发送的消息
+// SQLite 单写者，一条 UPDATE ... RETURNING 即原子完成领取
+func (s *OutboxStore) Claim(ctx context.Context, owner string, lease time.Duration, limit int) ([]event.OutboxRecord, error) {
+	const q = `
+UPDATE outbox SET claimed_by = ?1, claimed_until = ?2
+WHERE id IN (
+  SELECT o.id FROM outbox o
+  WHERE o.status = 'pending'
+    AND (o.next_attempt_at IS NULL OR o.next_attempt_at <= ?3)
+    AND (o.claimed_until IS NULL OR o.claimed_until < ?3 OR o.claimed_by = ?1)
+    -- 同 key 有更早的行在退避、或被别人持有时跳过，保证按 key 有序；空 key 不参与排序约束
+    AND (o.msg_key = '' OR NOT EXISTS (
+      SELECT 1 FROM outbox p
+      WHERE p.msg_key = o.msg_key AND p.status = 'pending' AND p.id < o.id
+        AND (p.next_attempt_at > ?3 OR (p.claimed_until >= ?3 AND p.claimed_by <> ?1))
+    ))
+  ORDER BY o.id
+  LIMIT ?4
+)
+RETURNING id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'), attempts, created_at`
+
+	t := time.Now()
+	rows, err := s.db.QueryContext(ctx, q, owner, at(t.Add(lease)), at(t), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []event.OutboxRecord
+	for rows.Next() {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.Attempts, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING 不保证顺序
+	slices.SortFunc(res, func(a, b event.OutboxRecord) int { return cmp.Compare(a.ID, b.ID) })
+	return res, nil
+}
+
+// MarkSent 一条 UPDATE 标记整批
+func (s *OutboxStore) MarkSent(ctx context.Context, owner string, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	in, args := inClause(ids)
+	q := `UPDATE outbox SET status = 'sent', sent_at = ? WHERE status = 'pending' AND COALESCE(claimed_by, '') = ? AND id IN (` + in + `)`
+	res, err := s.db.ExecContext(ctx, q, append([]any{now(), owner}, args...)...)
+	return checkMarked(res, err, len(ids))
+}
+
+func (s *OutboxStore) MarkFailed(ctx context.Context, owner string, id uint64, lastErr string, retryIn time.Duration, dead bool) error {
+	const q = `
+UPDATE outbox
+SET attempts = attempts + 1,
+    last_error = ?,
+    next_attempt_at = ?,
+    status = CASE WHEN ? THEN 'dead' ELSE status END,
+    claimed_by = NULL, claimed_until = NULL
+WHERE id = ? AND status = 'pending' AND COALESCE(claimed_by, '') = ?`
+	res, err := s.db.ExecContext(ctx, q, lastErr, at(time.Now().Add(retryIn)), dead, int64(id), owner)
+	return checkMarked(res, err, 1)
+}
+
+// checkMarked：改到的行数少于 want 说明有行已经不在自己名下
+func checkMarked(res sql.Result, err error, want int) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n < int64(want) {
+		return fmt.Errorf("%w: marked %d of %d rows", event.ErrLeaseLost, n, want)
+	}
+	return nil
+}
+
+// 给清理任务用：列出 before 之前已发送的行
+func (s *OutboxStore) ListSentBefore(ctx context.Context, before time.Time, limit int) ([]event.OutboxRecord, error) {
+	const q = `
+SELECT id, topic, msg_key, event_type, payload, COALESCE(headers, 'null'), created_at, sent_at
+FROM outbox
+WHERE sent_at IS NOT NULL AND sent_at < ?
+ORDER BY id
+LIMIT ?`
+
+	rows, err := s.db.QueryContext(ctx, q, at(before), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []event.OutboxRecord
+	for rows.Next() {
+		var r event.OutboxRecord
+		if err := rows.Scan(&r.ID, &r.Topic, &r.MsgKey, &r.Type, &r.Payload, &r.Headers, &r.CreatedAt, &r.SentAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+func (s *OutboxStore) Delete(ctx context.Context, ids []uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	in, args := inClause(ids)
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at IS NOT NULL AND id IN (`+in+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Stats：SQLite 不单独统计表大小，Bytes 恒为 0
+func (s *OutboxStore) Stats(ctx context.Context) (event.OutboxStats, error) {
+	var st event.OutboxStats
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(*) FILTER (WHERE status = 'dead') FROM outbox`).Scan(&st.Rows, &st.Dead)
+	return st, err
+}
+
+// inClause 生成 "?, ?, ?" 和对应参数
+func inClause(ids []uint64) (string, []any) {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = int64(id)
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/event"
+)
+
+func addOutbox(t *testing.T, s *OutboxStore, key string) {
+	t.Helper()
+	err := s.Add(context.Background(), event.OutboxMessage{
+		Topic: "user-events", Key: key, Type: "UserCreated", Payload: map[string]any{"key": key},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func claimIDs(t *testing.T, s *OutboxStore, owner string) []uint64 {
+	t.Helper()
+	recs, err := s.Claim(context.Background(), owner, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]uint64, len(recs))
+	for i, r := range recs {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func equalIDs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOutboxClaimMarkSent(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStore(openTestDB(t))
+	addOutbox(t, s, "a")
+	addOutbox(t, s, "b")
+	addOutbox(t, s, "a")
+
+	recs, err := s.Claim(ctx, "w1", time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 || recs[0].ID != 1 || recs[1].ID != 2 || recs[2].ID != 3 {
+		t.Fatalf("Claim = %+v, want ids 1,2,3", recs)
+	}
+	if r := recs[0]; r.Topic != "user-events" || r.MsgKey != "a" || r.Type != "UserCreated" || string(r.Payload) != `{"key":"a"}` {
+		t.Errorf("record = %+v", r)
+	}
+
+	// 租约期内别人领不到，自己可以续领
+	if ids := claimIDs(t, s, "w2"); len(ids) != 0 {
+		t.Errorf("w2 claimed %v during w1's lease", ids)
+	}
+	if ids := claimIDs(t, s, "w1"); !equalIDs(ids, []uint64{1, 2, 3}) {
+		t.Errorf("w1 renewed %v, want [1 2 3]", ids)
+	}
+
+	if err := s.MarkSent(ctx, "w1", []uint64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := claimIDs(t, s, "w1"); !equalIDs(ids, []uint64{3}) {
+		t.Errorf("after MarkSent claimed %v, want [3]", ids)
+	}
+}
+
+func TestOutboxClaimExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStore(openTestDB(t))
+	addOutbox(t, s, "a")
+
+	if _, err := s.Claim(ctx, "w1", -time.Second, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ids := claimIDs(t, s, "w2"); !equalIDs(ids, []uint64{1}) {
+		t.Errorf("w2 claimed %v after w1's lease expired, want [1]", ids)
+	}
+}
+
+func TestOutboxMarkLeaseLost(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStore(openTestDB(t))
+	addOutbox(t, s, "a")
+	addOutbox(t, s, "b")
+
+	// w1 的租约过期后 1、2 被 w2 接手
+	if _, err := s.Claim(ctx, "w1", -time.Second, 10); err != nil {
+		t.Fatal(err)
+	}
+	if ids := claimIDs(t, s, "w2"); !equalIDs(ids, []uint64{1, 2}) {
+		t.Fatalf("w2 claimed %v, want [1 2]", ids)
+	}
+
+	// w1 迟到的标记不生效
+	if err := s.MarkSent(ctx, "w1", []uint64{1}); !errors.Is(err, event.ErrLeaseLost) {
+		t.Errorf("stale MarkSent = %v, want ErrLeaseLost", err)
+	}
+	if err := s.MarkFailed(ctx, "w1", 2, "boom", time.Hour, true); !errors.Is(err, event.ErrLeaseLost) {
+		t.Errorf("stale MarkFailed = %v, want ErrLeaseLost", err)
+	}
+	// 没领过租约的 owner 也标记不了
+	if err := s.MarkSent(ctx, "", []uint64{1}); !errors.Is(err, event.ErrLeaseLost) {
+		t.Errorf("unclaimed MarkSent = %v, want ErrLeaseLost", err)
+	}
+	if ids := claimIDs(t, s, "w2"); !equalIDs(ids, []uint64{1, 2}) {
+		t.Errorf("after stale marks w2 claimed %v, want [1 2]", ids)
+	}
+
+	// 持有者可以标记；部分行不在名下时其余照常标记
+	if err := s.MarkSent(ctx, "w2", []uint64{1, 2, 3}); !errors.Is(err, event.ErrLeaseLost) {
+		t.Errorf("MarkSent with unknown id = %v, want ErrLeaseLost", err)
+	}
+	if ids := claimIDs(t, s, "w2"); len(ids) != 0 {
+		t.Errorf("after MarkSent claimed %v, want none", ids)
+	}
+}
+
+func TestOutboxMarkFailed(t *testing.T) {
+	ctx := context.Background()
+	s := NewOutboxStore(openTestDB(t))
+	addOutbox(t, s, "a")
+	addOutbox(t, s, "a")
+	addOutbox(t, s, "b")
+
+	// 1 退避中：同 key 的 2 也不能领，不同 key 的 3 不受影响
+	if err := s.MarkFailed(ctx, "", 1, "boom", time.Hour, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkFailed(ctx, "", 3, "boom", 0, false); err != nil {
+		t.Fatal(err)
+	}
+	recs, err := s.Claim(ctx, "w2", time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].ID != 3 || recs[0].Attempts != 1 {
+		t.Fatalf("Claim after MarkFailed = %+v, want only id 3 with attempts 1", recs)
+	}
+
+	// 1 进入 dead 后不再阻塞同 key 的后续消息
+	if err := s.MarkFailed(ctx, "", 1, "boom", 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if ids := claimIDs(t, s, "w2"); !equalIDs(ids, []uint64{2, 3}) {
+		t.Errorf("after dead claimed %v, want [2 3]", ids)
+	}
+	st, err := s.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Rows != 3 || st.Dead != 1 {
+		t.Errorf("Stats = %+v, want 3 rows, 1 dead", st)
+	}
+}
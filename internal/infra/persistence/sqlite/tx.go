@@ -0,0 +1,42 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
+)
+
+type Transactor = sqltx.Transactor
+
+func NewTransactor(db *sql.DB, retry sqltx.RetryConfig) *Transactor {
+	return sqltx.New(db, retry, isRetryable)
+}
+
+// SQLITE_BUSY / SQLITE_LOCKED：别的连接持有写锁
+func isRetryable(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	code := se.Code() & 0xff // 扩展错误码的低 8 位是主错误码
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// 内部给 repo / outbox 取执行器
+type execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
+func getExecer(db *sql.DB, ctx context.Context) execer {
+	if tx, ok := sqltx.From(ctx, db); ok {
+		return tx
+	}
+	return db
+}
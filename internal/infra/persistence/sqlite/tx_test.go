@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/infra/persistence/sqltx"
+)
+
+// openFileDB：两个 *sql.DB 打开同一个文件库，模拟两个进程争写锁；不设 busy_timeout，拿不到锁立即报 SQLITE_BUSY
+func openFileDB(t *testing.T) (*sql.DB, *sql.DB) {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "tx.db")
+	var dbs [2]*sql.DB
+	for i := range dbs {
+		db, err := Open(Config{DSN: dsn})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		dbs[i] = db
+	}
+	if _, err := dbs[0].Exec(`CREATE TABLE items (name TEXT NOT NULL UNIQUE)`); err != nil {
+		t.Fatal(err)
+	}
+	return dbs[0], dbs[1]
+}
+
+// lockDB 在 db 上开一个写事务占住写锁，返回释放函数
+func lockDB(t *testing.T, db *sql.DB) func() {
+	t.Helper()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(context.Background(), `BEGIN IMMEDIATE`); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), `ROLLBACK`)
+		_ = conn.Close()
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	holder, db := openFileDB(t)
+
+	unlock := lockDB(t, holder)
+	_, busy := db.Exec(`INSERT INTO items (name) VALUES ('a')`)
+	unlock()
+	if busy == nil {
+		t.Fatal("write succeeded while another connection held the lock")
+	}
+
+	if _, err := db.Exec(`INSERT INTO items (name) VALUES ('a')`); err != nil {
+		t.Fatal(err)
+	}
+	_, unique := db.Exec(`INSERT INTO items (name) VALUES ('a')`)
+	_, syntax := db.Exec(`INSERT INTO nope VALUES (1)`)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"busy", busy, true},
+		{"wrapped busy", fmt.Errorf("create user: %w", busy), true},
+		{"unique constraint", unique, false},
+		{"no such table", syntax, false},
+		{"not a driver error", errors.New("boom"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestTransactorRetriesBusy(t *testing.T) {
+	holder, db := openFileDB(t)
+	tr := NewTransactor(db, sqltx.RetryConfig{MaxRetries: 50, BaseDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond})
+
+	unlock := lockDB(t, holder)
+	time.AfterFunc(50*time.Millisecond, unlock)
+
+	attempts := 0
+	err := tr.WithinTx(context.Background(), func(ctx context.Context) error {
+		attempts++
+		_, err := getExecer(db, ctx).ExecContext(ctx, `INSERT INTO items (name) VALUES ('a')`)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts < 2 {
+		t.Errorf("attempts = %d, want a retry while the lock was held", attempts)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("rows = %d, %v; want 1", n, err)
+	}
+}
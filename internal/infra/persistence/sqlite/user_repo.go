@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+)
+
+type UserRepo struct {
+	db *sql.DB
+}
+
+func NewUserRepo(db *sql.DB) *UserRepo {
+	return &UserRepo{db: db}
+}
+
+func (r *UserRepo) Create(ctx context.Context, id uint64, name, email string) (user.User, error) {
+	ex := getExecer(r.db, ctx)
+
+	const q = `INSERT INTO users (id, name, email) VALUES (?, ?, ?) RETURNING id, name, email, version, created_at`
+
+	var u user.User
+	err := ex.QueryRowContext(ctx, q, int64(id), name, email).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
+	if err != nil {
+		var se *sqlite.Error
+		if errors.As(err, &se) && se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return user.User{}, user.ErrEmailExists
+		}
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id uint64) (user.User, error) {
+	ex := getExecer(r.db, ctx)
+
+	const q = `SELECT id, name, email, version, created_at FROM users WHERE id = ? LIMIT 1`
+
+	var u user.User
+	err := ex.QueryRowContext(ctx, q, id).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, user.ErrNotFound
+		}
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	ex := getExecer(r.db, ctx)
+
+	const q = `SELECT id, name, email, version, created_at FROM users WHERE email = ? LIMIT 1`
+
+	var u user.User
+	err := ex.QueryRowContext(ctx, q, email).Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, user.ErrNotFound
+		}
+		return user.User{}, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hacker4257/go-ddd-template/internal/domain/user"
+)
+
+func TestUserRepo(t *testing.T) {
+	ctx := context.Background()
+	r := NewUserRepo(openTestDB(t))
+
+	u, err := r.Create(ctx, 42, "alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 42 || u.Name != "alice" || u.Email != "alice@example.com" || u.Version != 1 || u.CreatedAt.IsZero() {
+		t.Fatalf("Create = %+v", u)
+	}
+
+	got, err := r.GetByID(ctx, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != u {
+		t.Errorf("GetByID = %+v, want %+v", got, u)
+	}
+	got, err = r.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != u {
+		t.Errorf("GetByEmail = %+v, want %+v", got, u)
+	}
+
+	if _, err := r.Create(ctx, 43, "alice2", "alice@example.com"); !errors.Is(err, user.ErrEmailExists) {
+		t.Errorf("Create with duplicate email: err = %v, want ErrEmailExists", err)
+	}
+	if _, err := r.GetByID(ctx, 43); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("GetByID(missing): err = %v, want ErrNotFound", err)
+	}
+	if _, err := r.GetByEmail(ctx, "bob@example.com"); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("GetByEmail(missing): err = %v, want ErrNotFound", err)
+	}
+}
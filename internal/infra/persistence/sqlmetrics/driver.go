@@ -0,0 +1,195 @@
+package sqlmetrics
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/trace"
+)
+
+// Options：SlowQuery 为 0 时不记慢查询日志
+type Options struct {
+	SlowQuery time.Duration
+	Logger    *slog.Logger
+}
+
+// Wrap 包一层 driver.Connector：每条语句按名字记录次数、耗时、错误，超阈值打慢查询日志
+// 用法：sql.OpenDB(sqlmetrics.Wrap(connector, opts))
+func Wrap(c driver.Connector, opts Options) driver.Connector {
+	return &connector{Connector: c, o: &observer{opts: opts}}
+}
+
+type observer struct {
+	opts Options
+}
+
+func (o *observer) observe(ctx context.Context, query string, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return // database/sql 会改走 prepare，由 stmt 那一层再记
+	}
+	d := time.Since(start)
+	name := StatementName(query)
+
+	metrics.DBQueriesTotal.Add(name, 1)
+	metrics.DBQueryDurationUs.Add(name, d.Microseconds())
+	if err != nil {
+		metrics.DBQueryErrorsTotal.Add(name, 1)
+	}
+
+	if o.opts.SlowQuery <= 0 || d < o.opts.SlowQuery {
+		return
+	}
+	metrics.DBSlowQueriesTotal.Add(1)
+	if o.opts.Logger != nil {
+		o.opts.Logger.Warn("slow_query",
+			slog.String("request_id", trace.RequestID(ctx)),
+			slog.String("statement", name),
+			slog.Int64("duration_ms", d.Milliseconds()),
+			slog.String("sql", truncate(query, 512)),
+			slog.Any("err", err),
+		)
+	}
+}
+
+type connector struct {
+	driver.Connector
+	o *observer
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: cn, o: c.o}, nil
+}
+
+// conn：只拦截执行语句的入口，其他能力原样转发给底层连接
+type conn struct {
+	driver.Conn
+	o *observer
+}
+
+var (
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+)
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // 老驱动兜底
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		st  driver.Stmt
+		err error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = p.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{Stmt: st, query: query, o: c.o}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	c.o.observe(ctx, query, start, err)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	c.o.observe(ctx, query, start, err)
+	return rows, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// stmt：go-sql-driver/mysql 未开 interpolateParams 时带参数的语句都走这里
+type stmt struct {
+	driver.Stmt
+	query string
+	o     *observer
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errors.New("sqlmetrics: driver stmt does not support ExecContext")
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, args)
+	s.o.observe(ctx, s.query, start, err)
+	return res, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errors.New("sqlmetrics: driver stmt does not support QueryContext")
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, args)
+	s.o.observe(ctx, s.query, start, err)
+	return rows, err
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,51 @@
+package sqlmetrics
+
+import (
+	"strings"
+)
+
+// StatementName：语句开头的 /* name */ 注释优先；没有则按 “动词_表名” 推断，如 select_users
+// 名字会作为 expvar 的 key，必须保证基数有限，所以不能带参数值
+func StatementName(query string) string {
+	q := strings.TrimSpace(query)
+	if strings.HasPrefix(q, "/*") {
+		if end := strings.Index(q, "*/"); end > 0 {
+			if name := strings.TrimSpace(q[2:end]); name != "" {
+				return name
+			}
+			q = strings.TrimSpace(q[end+2:])
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(q))
+	if len(words) == 0 {
+		return "unknown"
+	}
+	verb := words[0]
+
+	var table string
+	switch verb {
+	case "select", "delete":
+		table = wordAfter(words, "from")
+	case "insert", "replace":
+		table = wordAfter(words, "into")
+	case "update":
+		if len(words) > 1 {
+			table = words[1]
+		}
+	}
+	table = strings.Trim(table, "`\"();,")
+	if table == "" {
+		return verb
+	}
+	return verb + "_" + table
+}
+
+func wordAfter(words []string, kw string) string {
+	for i := 0; i < len(words)-1; i++ {
+		if words[i] == kw {
+			return words[i+1]
+		}
+	}
+	return ""
+}
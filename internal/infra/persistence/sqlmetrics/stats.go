@@ -0,0 +1,51 @@
+package sqlmetrics
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// ExportStats 定期把连接池状态写到 metrics；返回的函数用于停止
+func ExportStats(db *sql.DB, interval time.Duration) (stop func() error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			publish(db.Stats())
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() error {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+		return nil
+	}
+}
+
+func publish(s sql.DBStats) {
+	metrics.DBPoolOpen.Set(int64(s.OpenConnections))
+	metrics.DBPoolInUse.Set(int64(s.InUse))
+	metrics.DBPoolIdle.Set(int64(s.Idle))
+	metrics.DBPoolWaitCount.Set(s.WaitCount)
+	metrics.DBPoolWaitDurationMs.Set(s.WaitDuration.Milliseconds())
+}
@@ -0,0 +1,168 @@
+package sqltx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hacker4257/go-ddd-template/internal/app/tx"
+	"github.com/hacker4257/go-ddd-template/internal/pkg/metrics"
+)
+
+// RetryConfig：最外层事务遇到可重试错误（死锁、锁等待超时等）时整体重跑
+// BaseDelay / MaxDelay 不大于 0 时用默认值（20ms / 1s）
+type RetryConfig struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+type txKey struct{}
+
+type txState struct {
+	db    *sql.DB
+	tx    *sql.Tx
+	depth int       // 0 = 最外层
+	hooks *tx.Hooks // 本层的提交/回滚回调
+}
+
+// Transactor：database/sql 通用实现，各 driver 只提供“哪些错误可以重试”
+type Transactor struct {
+	db        *sql.DB
+	retry     RetryConfig
+	retryable func(error) bool
+}
+
+func New(db *sql.DB, retry RetryConfig, retryable func(error) bool) *Transactor {
+	return &Transactor{db: db, retry: retry, retryable: retryable}
+}
+
+func (t *Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error, opts ...tx.Option) error {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok && st.db == t.db {
+		return t.nested(ctx, st, fn)
+	}
+
+	o := tx.Apply(opts)
+	for attempt := 0; ; attempt++ {
+		err := t.run(ctx, fn, &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly})
+		if err == nil || t.retryable == nil || !t.retryable(err) {
+			return err
+		}
+		if attempt >= t.retry.MaxRetries {
+			metrics.DBTxRetryExhaustedTotal.Add(1)
+			return err
+		}
+
+		metrics.DBTxRetriesTotal.Add(1)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(t.backoff(attempt)):
+		}
+	}
+}
+
+func (t *Transactor) run(ctx context.Context, fn func(ctx context.Context) error, opts *sql.TxOptions) (err error) {
+	sqlTx, err := t.db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// 回调拿到的是不带事务的 ctx，且不受调用方取消影响
+	hookCtx := context.WithoutCancel(ctx)
+	st := &txState{db: t.db, tx: sqlTx}
+	txCtx, hooks := tx.WithHooks(context.WithValue(ctx, txKey{}, st))
+	st.hooks = hooks
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			hooks.RunRollback(hookCtx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(txCtx); err != nil {
+		_ = sqlTx.Rollback()
+		hooks.RunRollback(hookCtx)
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		hooks.RunRollback(hookCtx)
+		return err
+	}
+	hooks.RunCommit(hookCtx)
+	return nil
+}
+
+// nested：加入外层事务，本层失败只回滚到自己的 savepoint，错误照常返回给外层决定
+// 本层登记的回调：回滚到 savepoint 时立即触发 after-rollback；释放成功则并入外层
+func (t *Transactor) nested(ctx context.Context, parent *txState, fn func(ctx context.Context) error) (err error) {
+	st := &txState{db: parent.db, tx: parent.tx, depth: parent.depth + 1}
+	name := fmt.Sprintf("sp_%d", st.depth)
+
+	if _, err := st.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	hookCtx := context.WithoutCancel(ctx)
+	spCtx, hooks := tx.WithHooks(context.WithValue(ctx, txKey{}, st))
+	st.hooks = hooks
+
+	rollback := func() {
+		_, _ = st.tx.ExecContext(hookCtx, "ROLLBACK TO SAVEPOINT "+name)
+		hooks.RunRollback(hookCtx)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(spCtx); err != nil {
+		rollback()
+		return err
+	}
+	if _, err := st.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		rollback()
+		return err
+	}
+	parent.hooks.Merge(hooks)
+	return nil
+}
+
+const (
+	defaultRetryBaseDelay = 20 * time.Millisecond
+	defaultRetryMaxDelay  = time.Second
+)
+
+// backoff：指数退避 + full jitter，返回 (0, min(base<<attempt, MaxDelay)]
+func (t *Transactor) backoff(attempt int) time.Duration {
+	base, ceil := t.retry.BaseDelay, t.retry.MaxDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	if ceil <= 0 {
+		ceil = defaultRetryMaxDelay
+	}
+	ceil = max(ceil, base)
+
+	// 先比较再移位：base<<attempt 在 attempt 较大时会溢出成 0 或负数
+	d := ceil
+	if attempt >= 0 && base <= ceil>>attempt {
+		d = base << attempt
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// From 取 ctx 里属于 db 的事务（给各 driver 的 repo 选执行器）
+func From(ctx context.Context, db *sql.DB) (*sql.Tx, bool) {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok && st.db == db {
+		return st.tx, true
+	}
+	return nil, false
+}
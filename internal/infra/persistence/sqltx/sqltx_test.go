@@ -0,0 +1,316 @@
+package sqltx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/hacker4257/go-ddd-template/internal/app/tx"
+)
+
+var errDeadlock = errors.New("deadlock")
+
+func isDeadlock(err error) bool { return errors.Is(err, errDeadlock) }
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // 内存库每个连接是独立的库
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(`CREATE TABLE items (name TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func insert(ctx context.Context, db *sql.DB, name string) error {
+	ex, ok := From(ctx, db)
+	if !ok {
+		return errors.New("not in a transaction")
+	}
+	_, err := ex.ExecContext(ctx, `INSERT INTO items (name) VALUES (?)`, name)
+	return err
+}
+
+func items(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT name FROM items ORDER BY rowid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, n)
+	}
+	return names
+}
+
+func TestBackoffBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   RetryConfig
+		attempt int
+		wantMax time.Duration
+	}{
+		{"first attempt", RetryConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 0, 10 * time.Millisecond},
+		{"doubles", RetryConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 3, 80 * time.Millisecond},
+		{"capped", RetryConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 10, time.Second},
+		{"shift overflow", RetryConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 62, time.Second},
+		{"shift past width", RetryConfig{BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}, 200, time.Second},
+		{"zero max delay", RetryConfig{BaseDelay: 10 * time.Millisecond}, 70, defaultRetryMaxDelay},
+		{"zero config", RetryConfig{}, 0, defaultRetryBaseDelay},
+		{"negative config", RetryConfig{BaseDelay: -1, MaxDelay: -1}, 64, defaultRetryMaxDelay},
+		{"max below base", RetryConfig{BaseDelay: time.Second, MaxDelay: time.Millisecond}, 5, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New(nil, tt.retry, nil)
+			for range 200 {
+				if d := tr.backoff(tt.attempt); d <= 0 || d > tt.wantMax {
+					t.Fatalf("backoff(%d) = %v, want (0, %v]", tt.attempt, d, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDeadlock(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	tr := New(db, RetryConfig{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, isDeadlock)
+
+	var attempts, commits, rollbacks int
+	err := tr.WithinTx(ctx, func(ctx context.Context) error {
+		attempts++
+		tx.AfterCommit(ctx, func(context.Context) { commits++ })
+		tx.AfterRollback(ctx, func(context.Context) { rollbacks++ })
+		if err := insert(ctx, db, "a"); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errDeadlock
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 前两次整体回滚，只留下最后一次的写入和提交回调
+	if attempts != 3 || commits != 1 || rollbacks != 2 {
+		t.Errorf("attempts %d, commits %d, rollbacks %d; want 3, 1, 2", attempts, commits, rollbacks)
+	}
+	if got := items(t, db); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("items = %v, want [a]", got)
+	}
+}
+
+func TestRetryStops(t *testing.T) {
+	db := openTestDB(t)
+	retry := RetryConfig{MaxRetries: 2, BaseDelay: 50 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	errOther := errors.New("constraint")
+
+	tests := []struct {
+		name      string
+		err       error
+		nested    bool
+		cancel    bool // 第一次失败时取消 ctx，退避等待中退出
+		wantCalls int
+	}{
+		{"retries exhausted", errDeadlock, false, false, 3},
+		{"not retryable", errOther, false, false, 1},
+		{"canceled while waiting", errDeadlock, false, true, 1},
+		// 嵌套层的错误交给外层决定：外层原样返回时整个事务重跑
+		{"nested error retried by outer", errDeadlock, true, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := New(db, retry, isDeadlock)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			calls := 0
+			fn := func(context.Context) error {
+				calls++
+				if tt.cancel {
+					cancel()
+				}
+				return tt.err
+			}
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				if tt.nested {
+					return tr.WithinTx(ctx, fn)
+				}
+				return fn(ctx)
+			})
+			if !errors.Is(err, tt.err) || calls != tt.wantCalls {
+				t.Errorf("err %v after %d calls; want %v after %d", err, calls, tt.err, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNestedSavepoints(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	tr := New(db, RetryConfig{}, nil)
+	errInner := errors.New("inner failed")
+
+	err := tr.WithinTx(ctx, func(ctx context.Context) error {
+		if err := insert(ctx, db, "outer"); err != nil {
+			return err
+		}
+		// 成功释放的 savepoint：写入随外层提交
+		if err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, db, "kept"); err != nil {
+				return err
+			}
+			// 两层嵌套：最里层失败只回滚自己
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				if err := insert(ctx, db, "deep"); err != nil {
+					return err
+				}
+				return errInner
+			})
+			if !errors.Is(err, errInner) {
+				t.Errorf("deep savepoint err = %v", err)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		// 失败的 savepoint：只回滚本层，外层照常提交
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			if err := insert(ctx, db, "dropped"); err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			t.Errorf("savepoint err = %v, want errInner", err)
+		}
+		return insert(ctx, db, "after")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := items(t, db), []string{"outer", "kept", "after"}; !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+
+	// 外层回滚时已释放的 savepoint 一起回滚
+	err = tr.WithinTx(ctx, func(ctx context.Context) error {
+		if err := tr.WithinTx(ctx, func(ctx context.Context) error { return insert(ctx, db, "released") }); err != nil {
+			return err
+		}
+		return errInner
+	})
+	if !errors.Is(err, errInner) {
+		t.Fatalf("err = %v, want errInner", err)
+	}
+	if got, want := items(t, db), []string{"outer", "kept", "after"}; !slices.Equal(got, want) {
+		t.Errorf("after outer rollback items = %v, want %v", got, want)
+	}
+}
+
+func TestSavepointHooks(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	tr := New(db, RetryConfig{}, nil)
+	errInner := errors.New("inner failed")
+
+	var got []string
+	record := func(s string) func(context.Context) {
+		return func(context.Context) { got = append(got, s) }
+	}
+
+	err := tr.WithinTx(ctx, func(ctx context.Context) error {
+		tx.AfterCommit(ctx, record("outer commit 1"))
+		tx.AfterRollback(ctx, record("outer rollback"))
+
+		// 释放成功：回调并入外层，按登记顺序排在外层已登记的之后
+		_ = tr.WithinTx(ctx, func(ctx context.Context) error {
+			tx.AfterCommit(ctx, record("released commit"))
+			tx.AfterRollback(ctx, record("released rollback"))
+			return nil
+		})
+
+		// 回滚到 savepoint：after-rollback 立即执行，after-commit 丢弃；里面已释放的更深一层同样处理
+		_ = tr.WithinTx(ctx, func(ctx context.Context) error {
+			tx.AfterCommit(ctx, record("failed commit"))
+			tx.AfterRollback(ctx, record("failed rollback 1"))
+			_ = tr.WithinTx(ctx, func(ctx context.Context) error {
+				tx.AfterCommit(ctx, record("failed deep commit"))
+				tx.AfterRollback(ctx, record("failed deep rollback"))
+				return nil
+			})
+			tx.AfterRollback(ctx, record("failed rollback 2"))
+			return errInner
+		})
+		got = append(got, "outer continues")
+
+		tx.AfterCommit(ctx, record("outer commit 2"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"failed rollback 1", "failed deep rollback", "failed rollback 2",
+		"outer continues",
+		"outer commit 1", "released commit", "outer commit 2",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("hooks ran\n  %v\nwant\n  %v", got, want)
+	}
+}
+
+func TestOuterRollbackHooks(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	tr := New(db, RetryConfig{}, nil)
+	errOuter := errors.New("outer failed")
+
+	var got []string
+	record := func(s string) func(context.Context) {
+		return func(context.Context) { got = append(got, s) }
+	}
+
+	err := tr.WithinTx(ctx, func(ctx context.Context) error {
+		tx.AfterRollback(ctx, record("outer rollback"))
+		_ = tr.WithinTx(ctx, func(ctx context.Context) error {
+			tx.AfterCommit(ctx, record("released commit"))
+			tx.AfterRollback(ctx, record("released rollback"))
+			return nil
+		})
+		return errOuter
+	})
+	if !errors.Is(err, errOuter) {
+		t.Fatalf("err = %v, want errOuter", err)
+	}
+	// 外层回滚：已释放的 savepoint 的 after-rollback 一起执行，after-commit 都不执行
+	if want := []string{"outer rollback", "released rollback"}; !slices.Equal(got, want) {
+		t.Errorf("hooks ran %v, want %v", got, want)
+	}
+
+	// 不在事务里：AfterCommit 立即执行，AfterRollback 忽略
+	got = nil
+	tx.AfterCommit(ctx, record("no tx commit"))
+	tx.AfterRollback(ctx, record("no tx rollback"))
+	if want := []string{"no tx commit"}; !slices.Equal(got, want) {
+		t.Errorf("outside a tx hooks ran %v, want %v", got, want)
+	}
+}
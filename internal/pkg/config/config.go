@@ -19,11 +19,70 @@ type Config struct {
 	Redis RedisConfig `koanf:"redis"`
 	Kafka KafkaConfig `koanf:"kafka"`
 	Worker WorkerConfig `koanf:"worker"`
+	ID     IDConfig     `koanf:"id"`
 
 }
 
+// IDConfig：Snowflake 节点号，多副本部署时每个进程必须不同（可用环境变量 ID_NODE 覆盖）
+// 节点号没有安全的默认值，未配置时为 -1，server 拒绝启动
+type IDConfig struct {
+	Node             int64         `koanf:"node"`
+	MaxClockBackward time.Duration `koanf:"max_clock_backward"` // 时钟回拨在该范围内等待，超出拒绝生成
+}
+
 type WorkerConfig struct {
-	HTTP WorkerHTTPConfig `koanf:"http"`
+	HTTP   WorkerHTTPConfig   `koanf:"http"`
+	Outbox WorkerOutboxConfig `koanf:"outbox"`
+	Inbox  WorkerInboxConfig  `koanf:"inbox"`
+}
+
+// WorkerInboxConfig：消费去重以数据库 inbox 为准；Redis 只做可选的快速过滤
+// Retention 要长于消息可能被重投的时间窗（Kafka 保留期、DLQ 重放周期）
+type WorkerInboxConfig struct {
+	Retention       time.Duration `koanf:"retention"`
+	PurgeInterval   time.Duration `koanf:"purge_interval"`
+	PurgeBatchSize  int           `koanf:"purge_batch_size"`
+	PurgeBatchPause time.Duration `koanf:"purge_batch_pause"`
+
+	// Redis 处理租约：LeaseTTL 内同一事件的重复消息退避等待，完成后标记保留 RedisTTL
+	RedisFilter bool          `koanf:"redis_filter"`
+	LeaseTTL    time.Duration `koanf:"lease_ttl"`
+	RedisTTL    time.Duration `koanf:"redis_ttl"`
+}
+
+// WorkerOutboxConfig：outbox 投递；多个 worker 副本靠租约分摊，持有者崩溃后租约过期由别人接手
+type WorkerOutboxConfig struct {
+	// poll：轮询 outbox 表；cdc：订阅 MySQL binlog，binlog 不可用时自动回退到轮询
+	Mode string `koanf:"mode"`
+
+	Lease time.Duration `koanf:"lease"`
+
+	// 批量投递：一批取满立即再取，空闲时从 PollInterval 退避到 MaxPollInterval
+	BatchSize       int           `koanf:"batch_size"`
+	PollInterval    time.Duration `koanf:"poll_interval"`
+	MaxPollInterval time.Duration `koanf:"max_poll_interval"`
+
+	// 分区投递：> 0 时按 msg_key 哈希分到 N 条 lane，lane 内严格有序、lane 间并行
+	Lanes int `koanf:"lanes"`
+
+	// 单行发布失败：指数退避重试，MaxAttempts 次后标记为 dead
+	MaxAttempts    int           `koanf:"max_attempts"`
+	RetryBaseDelay time.Duration `koanf:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `koanf:"retry_max_delay"`
+
+	// 保留期清理：Retention 为 0 关闭；ArchiveDir 非空时先归档成 gzip NDJSON 再删除
+	Retention       time.Duration `koanf:"retention"`
+	PurgeInterval   time.Duration `koanf:"purge_interval"`
+	PurgeBatchSize  int           `koanf:"purge_batch_size"`
+	PurgeBatchPause time.Duration `koanf:"purge_batch_pause"`
+	PurgeMaxPerRun  int           `koanf:"purge_max_per_run"`
+	ArchiveDir      string        `koanf:"archive_dir"`
+
+	// binlog 中继：以 CDCServerID 注册为从库；出错后轮询 CDCRetryInterval 再重试订阅，
+	// 期间每隔 CDCSweepInterval 用一次轮询补发失败或漏掉的行
+	CDCServerID      uint32        `koanf:"cdc_server_id"`
+	CDCRetryInterval time.Duration `koanf:"cdc_retry_interval"`
+	CDCSweepInterval time.Duration `koanf:"cdc_sweep_interval"`
 }
 
 type WorkerHTTPConfig struct {
@@ -37,15 +96,56 @@ type KafkaConfig struct {
 	UserDLQTopic  string   `koanf:"user_dlq_topic"`
 	ConsumerGroup string   `koanf:"consumer_group"`
 	MaxRetries    int      `koanf:"max_retries"`
+
+	CacheConsumerGroup string `koanf:"cache_consumer_group"` // 缓存同步用的独立 group
+
+	// 事件按 CloudEvents 1.0 发布：EventSource 为 source 属性，EventMode 为 binary / structured
+	EventSource string `koanf:"event_source"`
+	EventMode   string `koanf:"event_mode"`
 }
 
 
 
 type RedisConfig struct {
+	Mode     string        `koanf:"mode"` // standalone / sentinel / cluster
 	Addr     string        `koanf:"addr"`
+	Addrs    []string      `koanf:"addrs"` // sentinel 哨兵地址 / cluster 种子节点
+	Username string        `koanf:"username"`
 	Password string        `koanf:"password"`
 	DB       int           `koanf:"db"`
 	UserTTL  time.Duration `koanf:"user_ttl"`
+
+	MasterName       string `koanf:"master_name"`
+	SentinelPassword string `koanf:"sentinel_password"`
+
+	TLS                   bool `koanf:"tls"`
+	TLSInsecureSkipVerify bool `koanf:"tls_insecure_skip_verify"`
+
+	PoolSize     int           `koanf:"pool_size"`
+	MinIdleConns int           `koanf:"min_idle_conns"`
+	DialTimeout  time.Duration `koanf:"dial_timeout"`
+	ReadTimeout  time.Duration `koanf:"read_timeout"`
+	WriteTimeout time.Duration `koanf:"write_timeout"`
+	PoolTimeout  time.Duration `koanf:"pool_timeout"`
+
+	UserTTLJitter        float64       `koanf:"user_ttl_jitter"`
+	UserNegativeTTL      time.Duration `koanf:"user_negative_ttl"`
+	UserEarlyRefresh     bool          `koanf:"user_early_refresh"`
+	UserEarlyRefreshBeta float64       `koanf:"user_early_refresh_beta"`
+
+	// 进程内二级缓存（L1），失效通过 pub/sub 广播到所有副本
+	UserLocalCache      bool          `koanf:"user_local_cache"`
+	UserLocalSize       int           `koanf:"user_local_size"`
+	UserLocalTTL        time.Duration `koanf:"user_local_ttl"`
+	InvalidationChannel string        `koanf:"invalidation_channel"`
+
+	// outbox 提交后通过 pub/sub 唤醒 worker，省掉轮询等待；关闭后只靠轮询
+	OutboxNudge        bool   `koanf:"outbox_nudge"`
+	OutboxNudgeChannel string `koanf:"outbox_nudge_channel"`
+
+	// 缓存序列化：msgpack/json；value 超过 CompressOver 字节时 s2 压缩（0 不压缩）
+	CacheCodec        string `koanf:"cache_codec"`
+	CacheCompressOver int    `koanf:"cache_compress_over"`
 }
 
 
@@ -55,7 +155,16 @@ type AppConfig struct {
 }
 
 type DBConfig struct {
-	MySQL MySQLConfig `koanf:"mysql"`
+	Driver      string         `koanf:"driver"` // mysql / postgres / sqlite
+	MySQL       MySQLConfig    `koanf:"mysql"`
+	Postgres    PostgresConfig `koanf:"postgres"`
+	SQLite      SQLiteConfig   `koanf:"sqlite"`
+	AutoMigrate bool           `koanf:"auto_migrate"` // server 启动时自动执行 migrate up
+
+	// 死锁 / 锁等待超时时整个事务自动重试
+	TxMaxRetries     int           `koanf:"tx_max_retries"`
+	TxRetryBaseDelay time.Duration `koanf:"tx_retry_base_delay"`
+	TxRetryMaxDelay  time.Duration `koanf:"tx_retry_max_delay"`
 }
 
 type MySQLConfig struct {
@@ -63,8 +172,28 @@ type MySQLConfig struct {
 	MaxOpenConns    int           `koanf:"max_open_conns"`
 	MaxIdleConns    int           `koanf:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `koanf:"conn_max_lifetime"`
+
+	SlowQuery     time.Duration `koanf:"slow_query"`     // 超过该耗时记慢查询日志，0 关闭
+	StatsInterval time.Duration `koanf:"stats_interval"` // 连接池状态导出周期
+
+	// 只读副本：事务外的普通读走副本，延迟超过 ReplicaMaxLag 的副本摘除
+	Replicas             []string      `koanf:"replicas"`
+	ReplicaMaxLag        time.Duration `koanf:"replica_max_lag"`
+	ReplicaCheckInterval time.Duration `koanf:"replica_check_interval"`
 }
 
+type PostgresConfig struct {
+	DSN             string        `koanf:"dsn"`
+	MaxOpenConns    int           `koanf:"max_open_conns"`
+	MaxIdleConns    int           `koanf:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `koanf:"conn_max_lifetime"`
+}
+
+
+type SQLiteConfig struct {
+	DSN          string `koanf:"dsn"`
+	MaxOpenConns int    `koanf:"max_open_conns"`
+}
 
 type HTTPConfig struct {
 	Addr         string        `koanf:"addr"`
@@ -120,6 +249,22 @@ func Load(path string) (Config, error) {
 	if cfg.Log.Level == "" {
 		cfg.Log.Level = "info"
 	}
+	if cfg.DB.Driver == "" {
+		cfg.DB.Driver = "mysql"
+	}
+
+	if cfg.DB.TxMaxRetries == 0 {
+		cfg.DB.TxMaxRetries = 3
+	}
+
+	if cfg.DB.TxRetryBaseDelay == 0 {
+		cfg.DB.TxRetryBaseDelay = 20 * time.Millisecond
+	}
+
+	if cfg.DB.TxRetryMaxDelay == 0 {
+		cfg.DB.TxRetryMaxDelay = 500 * time.Millisecond
+	}
+
 	//mysql
 	if cfg.DB.MySQL.MaxOpenConns == 0 { 
 		cfg.DB.MySQL.MaxOpenConns = 10 
@@ -133,15 +278,94 @@ func Load(path string) (Config, error) {
 		cfg.DB.MySQL.ConnMaxLifetime = 30 * time.Minute 
 	}
 
+	if cfg.DB.MySQL.StatsInterval == 0 {
+		cfg.DB.MySQL.StatsInterval = 10 * time.Second
+	}
+
+	if cfg.DB.MySQL.ReplicaMaxLag == 0 {
+		cfg.DB.MySQL.ReplicaMaxLag = 5 * time.Second
+	}
+
+	if cfg.DB.MySQL.ReplicaCheckInterval == 0 {
+		cfg.DB.MySQL.ReplicaCheckInterval = 5 * time.Second
+	}
+
+	//postgres
+	if cfg.DB.Postgres.MaxOpenConns == 0 {
+		cfg.DB.Postgres.MaxOpenConns = 10
+	}
+
+	if cfg.DB.Postgres.MaxIdleConns == 0 {
+		cfg.DB.Postgres.MaxIdleConns = 5
+	}
+
+	if cfg.DB.Postgres.ConnMaxLifetime == 0 {
+		cfg.DB.Postgres.ConnMaxLifetime = 30 * time.Minute
+	}
+
+	//sqlite
+	if cfg.DB.SQLite.DSN == "" {
+		cfg.DB.SQLite.DSN = "file:go_ddd.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+
 	//redis
+	if cfg.Redis.Mode == "" {
+		cfg.Redis.Mode = "standalone"
+	}
+
 	if cfg.Redis.Addr == "" { 
 		cfg.Redis.Addr = "127.0.0.1:6379" 
 	}
 
+	if cfg.Redis.DialTimeout == 0 {
+		cfg.Redis.DialTimeout = 5 * time.Second
+	}
+
+	if cfg.Redis.ReadTimeout == 0 {
+		cfg.Redis.ReadTimeout = 3 * time.Second
+	}
+
+	if cfg.Redis.WriteTimeout == 0 {
+		cfg.Redis.WriteTimeout = 3 * time.Second
+	}
+
 	if cfg.Redis.UserTTL == 0 { 
 		cfg.Redis.UserTTL = 10 * time.Minute 
 	}
 
+	// 这两项 0 表示关闭，只在没有配置时才给默认值
+	if !k.Exists("redis.user_ttl_jitter") {
+		cfg.Redis.UserTTLJitter = 0.1
+	}
+
+	if !k.Exists("redis.user_negative_ttl") {
+		cfg.Redis.UserNegativeTTL = 30 * time.Second
+	}
+
+	if cfg.Redis.UserEarlyRefreshBeta == 0 {
+		cfg.Redis.UserEarlyRefreshBeta = 1
+	}
+
+	if cfg.Redis.UserLocalSize == 0 {
+		cfg.Redis.UserLocalSize = 10000
+	}
+
+	if cfg.Redis.UserLocalTTL == 0 {
+		cfg.Redis.UserLocalTTL = 5 * time.Second
+	}
+
+	if cfg.Redis.InvalidationChannel == "" {
+		cfg.Redis.InvalidationChannel = "cache:invalidate:user"
+	}
+
+	if cfg.Redis.OutboxNudgeChannel == "" {
+		cfg.Redis.OutboxNudgeChannel = "outbox:nudge"
+	}
+
+	if cfg.Redis.CacheCodec == "" {
+		cfg.Redis.CacheCodec = "msgpack"
+	}
+
 	//kafka
 	if len(cfg.Kafka.Brokers) == 0 { 
 		cfg.Kafka.Brokers = []string{"127.0.0.1:9092"} 
@@ -161,10 +385,103 @@ func Load(path string) (Config, error) {
 		cfg.Kafka.MaxRetries = 5 
 	}
 
+	if cfg.Kafka.CacheConsumerGroup == "" {
+		cfg.Kafka.CacheConsumerGroup = "go-ddd-template-cache"
+	}
+	if cfg.Kafka.EventSource == "" {
+		cfg.Kafka.EventSource = "/" + cfg.App.Name
+	}
+	if cfg.Kafka.EventMode == "" {
+		cfg.Kafka.EventMode = "binary"
+	}
+
 	if cfg.Worker.HTTP.Addr == "" { 
 		cfg.Worker.HTTP.Addr = ":9091" 
 	}
 
+	if cfg.Worker.Inbox.PurgeInterval == 0 {
+		cfg.Worker.Inbox.PurgeInterval = 10 * time.Minute
+	}
+
+	if cfg.Worker.Inbox.PurgeBatchSize == 0 {
+		cfg.Worker.Inbox.PurgeBatchSize = 500
+	}
+
+	if cfg.Worker.Inbox.PurgeBatchPause == 0 {
+		cfg.Worker.Inbox.PurgeBatchPause = 200 * time.Millisecond
+	}
+
+	if cfg.Worker.Inbox.LeaseTTL == 0 {
+		cfg.Worker.Inbox.LeaseTTL = 30 * time.Second
+	}
+
+	if cfg.Worker.Inbox.RedisTTL == 0 {
+		cfg.Worker.Inbox.RedisTTL = 24 * time.Hour
+	}
+
+	if cfg.Worker.Outbox.Mode == "" {
+		cfg.Worker.Outbox.Mode = "poll"
+	}
+
+	if cfg.Worker.Outbox.CDCServerID == 0 {
+		cfg.Worker.Outbox.CDCServerID = 4257
+	}
+
+	if cfg.Worker.Outbox.CDCRetryInterval == 0 {
+		cfg.Worker.Outbox.CDCRetryInterval = time.Minute
+	}
+
+	if cfg.Worker.Outbox.CDCSweepInterval == 0 {
+		cfg.Worker.Outbox.CDCSweepInterval = time.Minute
+	}
+
+	if cfg.Worker.Outbox.Lease == 0 {
+		cfg.Worker.Outbox.Lease = 30 * time.Second
+	}
+
+	if cfg.Worker.Outbox.BatchSize == 0 {
+		cfg.Worker.Outbox.BatchSize = 500
+	}
+
+	if cfg.Worker.Outbox.PollInterval == 0 {
+		cfg.Worker.Outbox.PollInterval = 100 * time.Millisecond
+	}
+
+	if cfg.Worker.Outbox.MaxPollInterval == 0 {
+		cfg.Worker.Outbox.MaxPollInterval = time.Second
+	}
+
+	if cfg.Worker.Outbox.MaxAttempts == 0 {
+		cfg.Worker.Outbox.MaxAttempts = 10
+	}
+
+	if cfg.Worker.Outbox.RetryBaseDelay == 0 {
+		cfg.Worker.Outbox.RetryBaseDelay = time.Second
+	}
+
+	if cfg.Worker.Outbox.RetryMaxDelay == 0 {
+		cfg.Worker.Outbox.RetryMaxDelay = 5 * time.Minute
+	}
+
+	if cfg.Worker.Outbox.PurgeInterval == 0 {
+		cfg.Worker.Outbox.PurgeInterval = 10 * time.Minute
+	}
+
+	if cfg.Worker.Outbox.PurgeBatchSize == 0 {
+		cfg.Worker.Outbox.PurgeBatchSize = 500
+	}
+
+	if cfg.Worker.Outbox.PurgeBatchPause == 0 {
+		cfg.Worker.Outbox.PurgeBatchPause = 200 * time.Millisecond
+	}
+
+	if !k.Exists("id.node") {
+		cfg.ID.Node = -1
+	}
+	if cfg.ID.MaxClockBackward == 0 {
+		cfg.ID.MaxClockBackward = time.Second
+	}
+
 
 	return cfg, nil
 }
@@ -0,0 +1,15 @@
+package consistency
+
+import "context"
+
+type strongKey struct{}
+
+// Strong 标记 ctx 需要读到自己刚写入的数据（read-your-writes），持久层应避开只读副本
+func Strong(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strongKey{}, true)
+}
+
+func IsStrong(ctx context.Context) bool {
+	v, _ := ctx.Value(strongKey{}).(bool)
+	return v
+}
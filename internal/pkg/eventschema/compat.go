@@ -0,0 +1,229 @@
+package eventschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Compare 列出从 old 改成 new 的不兼容修改：按 new 读旧消息、或按 old 读新消息会出错的变化。
+// 只看 type / format / enum / required / properties / additionalProperties / items，
+// 足够覆盖事件 payload 这种扁平结构；结果为空表示兼容
+func Compare(old, new []byte) ([]string, error) {
+	var o, n map[string]any
+	if err := json.Unmarshal(old, &o); err != nil {
+		return nil, fmt.Errorf("old schema: %w", err)
+	}
+	if err := json.Unmarshal(new, &n); err != nil {
+		return nil, fmt.Errorf("new schema: %w", err)
+	}
+	var out []string
+	compareNode(&out, "$", o, n)
+	return out, nil
+}
+
+func compareNode(out *[]string, path string, o, n map[string]any) {
+	add := func(format string, args ...any) {
+		*out = append(*out, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if ot, nt := schemaTypes(o), schemaTypes(n); !slices.Equal(ot, nt) {
+		add("type %v -> %v", ot, nt)
+	}
+	if of, nf := o["format"], n["format"]; of != nf {
+		add("format %v -> %v", of, nf)
+	}
+	if oe, ne := o["enum"], n["enum"]; !reflect.DeepEqual(oe, ne) {
+		add("enum %v -> %v", oe, ne)
+	}
+
+	or, nr := stringSet(o["required"]), stringSet(n["required"])
+	for _, k := range sortedKeys(nr) {
+		if !or[k] {
+			add("%q became required", k)
+		}
+	}
+	for _, k := range sortedKeys(or) {
+		if !nr[k] {
+			add("%q is no longer required", k)
+		}
+	}
+
+	op, np := object(o["properties"]), object(n["properties"])
+	oclosed, nclosed := o["additionalProperties"] == false, n["additionalProperties"] == false
+	for _, k := range sortedKeys(op) {
+		if _, ok := np[k]; !ok {
+			add("property %q removed", k)
+			continue
+		}
+		compareNode(out, path+"."+k, object(op[k]), object(np[k]))
+	}
+	for _, k := range sortedKeys(np) {
+		if _, ok := op[k]; !ok && oclosed {
+			add("property %q added but the old schema disallows additional properties", k)
+		}
+	}
+	if !oclosed && nclosed {
+		add("additional properties are no longer allowed")
+	}
+
+	if oi, ni := object(o["items"]), object(n["items"]); oi != nil || ni != nil {
+		compareNode(out, path+"[]", oi, ni)
+	}
+}
+
+// CheckStruct 核对 d.Payload 的 Go 结构体和 schema：schema 里的字段结构体都要有且 JSON 类型一致，
+// required 字段不能 omitempty；schema 禁止额外属性时，结构体也不能多出字段
+func CheckStruct(d *Definition) ([]string, error) {
+	if d.Payload == nil {
+		return nil, nil
+	}
+	var s map[string]any
+	if err := json.Unmarshal(d.Schema, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", d.URI(), err)
+	}
+	var out []string
+	checkType(&out, "$", s, reflect.TypeOf(d.Payload))
+	return out, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func checkType(out *[]string, path string, s map[string]any, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	kind := jsonKind(t)
+	if ts := schemaTypes(s); kind != "" && len(ts) > 0 &&
+		!slices.Contains(ts, kind) && !(kind == "integer" && slices.Contains(ts, "number")) {
+		*out = append(*out, fmt.Sprintf("%s: schema type %v, Go type %s", path, ts, t))
+		return
+	}
+
+	switch {
+	case kind == "array":
+		if items := object(s["items"]); items != nil {
+			checkType(out, path+"[]", items, t.Elem())
+		}
+	case kind == "object" && t.Kind() == reflect.Struct:
+		fields := jsonFields(t)
+		props := object(s["properties"])
+		required := stringSet(s["required"])
+		for _, k := range sortedKeys(props) {
+			f, ok := fields[k]
+			if !ok {
+				*out = append(*out, fmt.Sprintf("%s: property %q missing from %s", path, k, t))
+				continue
+			}
+			if required[k] && f.omitempty {
+				*out = append(*out, fmt.Sprintf("%s: required property %q is omitempty in %s", path, k, t))
+			}
+			checkType(out, path+"."+k, object(props[k]), f.typ)
+		}
+		if s["additionalProperties"] == false {
+			for _, k := range sortedKeys(fields) {
+				if _, ok := props[k]; !ok {
+					*out = append(*out, fmt.Sprintf("%s: field %q of %s is not in the schema", path, k, t))
+				}
+			}
+		}
+	}
+}
+
+// jsonKind：encoding/json 编码出来的 JSON 类型；interface 等无法确定时返回空
+func jsonKind(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // []byte 编码为 base64
+		}
+		return "array"
+	default:
+		return ""
+	}
+}
+
+type jsonField struct {
+	typ       reflect.Type
+	omitempty bool
+}
+
+// jsonFields：结构体按 json tag 展开后的字段（含匿名嵌入）
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = jsonField{typ: f.Type, omitempty: strings.Contains(","+opts+",", ",omitempty,")}
+	}
+	return fields
+}
+
+func schemaTypes(s map[string]any) []string {
+	var ts []string
+	switch v := s["type"].(type) {
+	case string:
+		ts = []string{v}
+	case []any:
+		for _, x := range v {
+			if str, ok := x.(string); ok {
+				ts = append(ts, str)
+			}
+		}
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+func stringSet(v any) map[string]bool {
+	set := make(map[string]bool)
+	arr, _ := v.([]any)
+	for _, x := range arr {
+		if s, ok := x.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+func object(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
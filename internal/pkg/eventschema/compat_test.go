@@ -0,0 +1,134 @@
+package eventschema
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const baseSchema = `{
+  "type": "object",
+  "required": ["id", "name"],
+  "properties": {
+    "id": {"type": "integer"},
+    "name": {"type": "string"},
+    "tags": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		new  string
+		want []string // 每条结果里应该出现的片段；为空表示兼容
+	}{
+		{"unchanged", baseSchema, nil},
+		{
+			"optional property added",
+			`{"type":"object","required":["id","name"],"properties":{"id":{"type":"integer"},"name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}},"email":{"type":"string"}}}`,
+			nil,
+		},
+		{
+			"property became required",
+			`{"type":"object","required":["id","name","tags"],"properties":{"id":{"type":"integer"},"name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}}}`,
+			[]string{`"tags" became required`},
+		},
+		{
+			"property no longer required",
+			`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"},"name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}}}`,
+			[]string{`"name" is no longer required`},
+		},
+		{
+			"property removed",
+			`{"type":"object","required":["id","name"],"properties":{"id":{"type":"integer"},"name":{"type":"string"}}}`,
+			[]string{`property "tags" removed`},
+		},
+		{
+			"type changed",
+			`{"type":"object","required":["id","name"],"properties":{"id":{"type":"string"},"name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}}}`,
+			[]string{"$.id: type [integer] -> [string]"},
+		},
+		{
+			"item type changed",
+			`{"type":"object","required":["id","name"],"properties":{"id":{"type":"integer"},"name":{"type":"string"},"tags":{"type":"array","items":{"type":"integer"}}}}`,
+			[]string{"$.tags[]: type [string] -> [integer]"},
+		},
+		{
+			"format added",
+			`{"type":"object","required":["id","name"],"properties":{"id":{"type":"integer"},"name":{"type":"string","format":"email"},"tags":{"type":"array","items":{"type":"string"}}}}`,
+			[]string{"$.name: format"},
+		},
+		{
+			"additional properties closed",
+			`{"type":"object","additionalProperties":false,"required":["id","name"],"properties":{"id":{"type":"integer"},"name":{"type":"string"},"tags":{"type":"array","items":{"type":"string"}}}}`,
+			[]string{"additional properties are no longer allowed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Compare([]byte(baseSchema), []byte(tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Compare = %q, want %d finding(s) matching %q", got, len(tt.want), tt.want)
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("finding %d = %q, want it to contain %q", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareClosedSchema(t *testing.T) {
+	old := `{"type":"object","additionalProperties":false,"properties":{"id":{"type":"integer"}}}`
+	new := `{"type":"object","additionalProperties":false,"properties":{"id":{"type":"integer"},"name":{"type":"string"}}}`
+	got, err := Compare([]byte(old), []byte(new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], `property "name" added`) {
+		t.Errorf("Compare = %q, want the added property flagged", got)
+	}
+}
+
+func TestCheckStruct(t *testing.T) {
+	type good struct {
+		ID        uint64    `json:"id"`
+		Name      string    `json:"name"`
+		Tags      []string  `json:"tags,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	type drifted struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+	}
+	schema := `{"type":"object","required":["id","name"],"properties":{
+	  "id":{"type":"integer"},"name":{"type":"string"},
+	  "tags":{"type":"array","items":{"type":"string"}},
+	  "created_at":{"type":"string","format":"date-time"}}}`
+
+	got, err := CheckStruct(&Definition{Type: "T", Version: 1, Schema: []byte(schema), Payload: good{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("CheckStruct(good) = %q, want no findings", got)
+	}
+
+	got, err = CheckStruct(&Definition{Type: "T", Version: 1, Schema: []byte(schema), Payload: drifted{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"property \"created_at\" missing", "$.id: schema type [integer]", "required property \"name\" is omitempty", "property \"tags\" missing"}
+	if len(got) != len(want) {
+		t.Fatalf("CheckStruct(drifted) = %q, want %d findings", got, len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(got[i], w) {
+			t.Errorf("finding %d = %q, want it to contain %q", i, got[i], w)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package eventschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// 事件 schema 注册表：每个事件类型的每个版本对应一份 JSON Schema（draft 2020-12）和一个 Go 结构体
+//   - 生产端写 outbox 前、消费端处理前都按 schema 校验 payload
+//   - 版本号写进 CloudEvents dataschema（见 URI），消费端据此选 schema
+//   - 已发布的版本只能做兼容修改（见 Compare），不兼容的修改要加新版本，并提供从上一版本转换的 Upcaster
+var (
+	ErrUnknown = errors.New("unknown event schema")
+	ErrInvalid = errors.New("invalid event payload")
+)
+
+// Upcaster 把上一个版本的 payload 转成本版本
+type Upcaster func(prev json.RawMessage) (json.RawMessage, error)
+
+type Definition struct {
+	Type    string
+	Version int      // 从 1 开始连续递增
+	Schema  []byte   // JSON Schema 原文
+	Payload any      // 该版本 payload 的 Go 类型（零值即可），CheckStruct 用它核对结构体和 schema 是否一致
+	Upcast  Upcaster // Version > 1 时必填：从 Version-1 升级过来
+
+	compiled *jsonschema.Schema
+}
+
+// URI：写进 dataschema 的标识
+func (d *Definition) URI() string {
+	return URI(d.Type, d.Version)
+}
+
+// Validate 按 schema 校验 JSON payload，失败时返回包装了 ErrInvalid 的错误
+func (d *Definition) Validate(data []byte) error {
+	v, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalid, d.URI(), err)
+	}
+	if err := d.compiled.Validate(v); err != nil {
+		// 校验错误是多行的，压成一行方便打日志
+		return fmt.Errorf("%w: %s: %s", ErrInvalid, d.URI(), strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+	return nil
+}
+
+type Registry struct {
+	defs map[string][]*Definition // type → 按版本升序
+}
+
+func NewRegistry(defs ...Definition) (*Registry, error) {
+	r := &Registry{defs: make(map[string][]*Definition)}
+	for _, d := range defs {
+		if err := r.Register(d); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register 编译并登记一个版本；同一类型的版本必须按 1、2、3… 的顺序登记
+func (r *Registry) Register(d Definition) error {
+	if d.Type == "" {
+		return errors.New("eventschema: empty event type")
+	}
+	if want := len(r.defs[d.Type]) + 1; d.Version != want {
+		return fmt.Errorf("eventschema: %s: version %d registered out of order, want %d", d.Type, d.Version, want)
+	}
+	if d.Version > 1 && d.Upcast == nil {
+		return fmt.Errorf("eventschema: %s: missing upcaster from v%d", d.URI(), d.Version-1)
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(d.Schema))
+	if err != nil {
+		return fmt.Errorf("eventschema: %s: %w", d.URI(), err)
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	if err := c.AddResource(d.URI(), doc); err != nil {
+		return fmt.Errorf("eventschema: %s: %w", d.URI(), err)
+	}
+	if d.compiled, err = c.Compile(d.URI()); err != nil {
+		return fmt.Errorf("eventschema: %s: %w", d.URI(), err)
+	}
+
+	r.defs[d.Type] = append(r.defs[d.Type], &d)
+	return nil
+}
+
+func (r *Registry) Lookup(typ string, version int) (*Definition, error) {
+	vs := r.defs[typ]
+	if version < 1 || version > len(vs) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknown, URI(typ, version))
+	}
+	return vs[version-1], nil
+}
+
+// Latest：生产端总是按最新版本发布
+func (r *Registry) Latest(typ string) (*Definition, error) {
+	vs := r.defs[typ]
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("%w: type %q", ErrUnknown, typ)
+	}
+	return vs[len(vs)-1], nil
+}
+
+// Resolve 按事件的 type 和 dataschema 找定义；dataschema 为空的旧消息（引入 schema 之前发布的）按 v1 处理
+func (r *Registry) Resolve(typ, dataschema string) (*Definition, error) {
+	if dataschema == "" {
+		return r.Lookup(typ, 1)
+	}
+	t, v, ok := ParseURI(dataschema)
+	if !ok || t != typ {
+		return nil, fmt.Errorf("%w: dataschema %q for type %q", ErrUnknown, dataschema, typ)
+	}
+	return r.Lookup(typ, v)
+}
+
+// Known：类型是否登记过；没登记的类型不归本服务处理
+func (r *Registry) Known(typ string) bool {
+	return len(r.defs[typ]) > 0
+}
+
+// Upcast 按 dataschema 校验 payload，再沿 Upcaster 链逐级转成最新版本（每一级都校验），
+// 返回最新版本的定义和转换后的 payload；已经是最新版本时原样返回
+func (r *Registry) Upcast(typ, dataschema string, data []byte) (*Definition, []byte, error) {
+	d, err := r.Resolve(typ, dataschema)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := d.Validate(data); err != nil {
+		return nil, nil, err
+	}
+	for _, next := range r.defs[typ][d.Version:] {
+		out, err := next.Upcast(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: upcast %s -> %s: %v", ErrInvalid, d.URI(), next.URI(), err)
+		}
+		if err := next.Validate(out); err != nil {
+			return nil, nil, fmt.Errorf("upcast %s -> %s: %w", d.URI(), next.URI(), err)
+		}
+		d, data = next, out
+	}
+	return d, data, nil
+}
+
+// Types 按名字排序
+func (r *Registry) Types() []string {
+	ts := make([]string, 0, len(r.defs))
+	for t := range r.defs {
+		ts = append(ts, t)
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+// Versions 按版本升序
+func (r *Registry) Versions(typ string) []*Definition {
+	return r.defs[typ]
+}
+
+const uriPrefix = "urn:event:"
+
+// URI：urn:event:<type>:v<version>
+func URI(typ string, version int) string {
+	return uriPrefix + typ + ":v" + strconv.Itoa(version)
+}
+
+func ParseURI(s string) (typ string, version int, ok bool) {
+	rest, found := strings.CutPrefix(s, uriPrefix)
+	if !found {
+		return "", 0, false
+	}
+	i := strings.LastIndex(rest, ":v")
+	if i <= 0 {
+		return "", 0, false
+	}
+	v, err := strconv.Atoi(rest[i+2:])
+	if err != nil || v < 1 {
+		return "", 0, false
+	}
+	return rest[:i], v, true
+}
@@ -0,0 +1,129 @@
+package eventschema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// 三个版本的测试事件：v1 {n}，v2 把 n 改名为 count，v3 加上必填的 unit
+var testDefs = []Definition{
+	{
+		Type: "Measured", Version: 1,
+		Schema: []byte(`{"type":"object","required":["n"],"properties":{"n":{"type":"integer"}}}`),
+	},
+	{
+		Type: "Measured", Version: 2,
+		Schema: []byte(`{"type":"object","required":["count"],"properties":{"count":{"type":"integer"}}}`),
+		Upcast: func(prev json.RawMessage) (json.RawMessage, error) {
+			var v struct {
+				N int `json:"n"`
+			}
+			if err := json.Unmarshal(prev, &v); err != nil {
+				return nil, err
+			}
+			return json.Marshal(map[string]any{"count": v.N})
+		},
+	},
+	{
+		Type: "Measured", Version: 3,
+		Schema: []byte(`{"type":"object","required":["count","unit"],"properties":{"count":{"type":"integer"},"unit":{"type":"string"}}}`),
+		Upcast: func(prev json.RawMessage) (json.RawMessage, error) {
+			var m map[string]any
+			if err := json.Unmarshal(prev, &m); err != nil {
+				return nil, err
+			}
+			m["unit"] = "item"
+			return json.Marshal(m)
+		},
+	},
+}
+
+func TestRegistryUpcast(t *testing.T) {
+	reg, err := NewRegistry(testDefs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		dataschema string
+		data       string
+		want       string
+		wantErr    error
+	}{
+		{"legacy without dataschema", "", `{"n":2}`, `{"count":2,"unit":"item"}`, nil},
+		{"v1", "urn:event:Measured:v1", `{"n":3}`, `{"count":3,"unit":"item"}`, nil},
+		{"v2", "urn:event:Measured:v2", `{"count":4}`, `{"count":4,"unit":"item"}`, nil},
+		{"latest unchanged", "urn:event:Measured:v3", `{"count":5,"unit":"kg"}`, `{"count":5,"unit":"kg"}`, nil},
+		{"invalid source payload", "urn:event:Measured:v1", `{"count":1}`, "", ErrInvalid},
+		{"unknown version", "urn:event:Measured:v4", `{}`, "", ErrUnknown},
+		{"dataschema of another type", "urn:event:Other:v1", `{}`, "", ErrUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, out, err := reg.Upcast("Measured", tt.dataschema, []byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Version != 3 || d.URI() != "urn:event:Measured:v3" {
+				t.Errorf("definition = %s, want v3", d.URI())
+			}
+			if string(out) != tt.want {
+				t.Errorf("payload = %s, want %s", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryUpcastInvalidStep(t *testing.T) {
+	defs := append([]Definition(nil), testDefs[:2]...)
+	defs[1].Upcast = func(json.RawMessage) (json.RawMessage, error) {
+		return json.RawMessage(`{"count":"many"}`), nil // 转换结果不符合 v2
+	}
+	reg, err := NewRegistry(defs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := reg.Upcast("Measured", "", []byte(`{"n":1}`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []Definition
+	}{
+		{"version gap", []Definition{testDefs[0], testDefs[2]}},
+		{"starts at v2", []Definition{testDefs[1]}},
+		{"missing upcaster", []Definition{testDefs[0], {Type: "Measured", Version: 2, Schema: testDefs[1].Schema}}},
+		{"empty type", []Definition{{Version: 1, Schema: testDefs[0].Schema}}},
+		{"bad schema", []Definition{{Type: "Measured", Version: 1, Schema: []byte(`{"type":`)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegistry(tt.defs...); err == nil {
+				t.Error("NewRegistry succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	typ, v, ok := ParseURI(URI("UserCreated", 12))
+	if !ok || typ != "UserCreated" || v != 12 {
+		t.Errorf("ParseURI(URI) = %q, %d, %v", typ, v, ok)
+	}
+	for _, s := range []string{"", "UserCreated:v1", "urn:event:UserCreated", "urn:event::v1", "urn:event:UserCreated:v0", "urn:event:UserCreated:vx"} {
+		if _, _, ok := ParseURI(s); ok {
+			t.Errorf("ParseURI(%q) ok, want failure", s)
+		}
+	}
+}
@@ -13,7 +13,7 @@ import (
 
 type Checker struct {
 	DB      *sql.DB
-	Redis   *goredis.Client
+	Redis   goredis.UniversalClient
 	Brokers []string
 
 	Timeout time.Duration
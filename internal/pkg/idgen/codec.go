@@ -0,0 +1,78 @@
+package idgen
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// 对外的字符串形式：<prefix>_<13 位 Crockford base32>，如 usr_01j2k3m4n5p6q
+// 前缀保证不会和纯数字 ID 混淆；老的数字 ID 照样能编码
+//
+// 只是换了进制，不是加密：任何人都能解回 Snowflake，看出创建时间、节点号，也能猜出相邻的 ID。
+// 它解决的是 JS 数字精度和可读性，不能代替权限校验，不要当成不可猜的 token 用
+const (
+	alphabet   = "0123456789abcdefghjkmnpqrstvwxyz"
+	encodedLen = 13 // 13 * 5 = 65 位，够放 64 位
+)
+
+var ErrInvalidID = errors.New("idgen: invalid id")
+
+var decodeMap = func() [256]byte {
+	var m [256]byte
+	for i := range m {
+		m[i] = 0xff
+	}
+	for i := 0; i < len(alphabet); i++ {
+		m[alphabet[i]] = byte(i)
+		m[strings.ToUpper(alphabet[i : i+1])[0]] = byte(i)
+	}
+	// Crockford：易混字符按同一个值解析
+	for _, c := range "oO" {
+		m[c] = 0
+	}
+	for _, c := range "iIlL" {
+		m[c] = 1
+	}
+	return m
+}()
+
+func Encode(prefix string, id uint64) string {
+	var buf [encodedLen]byte
+	for i := encodedLen - 1; i >= 0; i-- {
+		buf[i] = alphabet[id&31]
+		id >>= 5
+	}
+	return prefix + "_" + string(buf[:])
+}
+
+func Decode(prefix, s string) (uint64, error) {
+	body, ok := strings.CutPrefix(s, prefix+"_")
+	if !ok || len(body) != encodedLen {
+		return 0, ErrInvalidID
+	}
+	var id uint64
+	for i := 0; i < len(body); i++ {
+		v := decodeMap[body[i]]
+		if v == 0xff {
+			return 0, ErrInvalidID
+		}
+		if i == 0 && v > 0x0f { // 首字符只有低 4 位有效，否则溢出 64 位
+			return 0, ErrInvalidID
+		}
+		id = id<<5 | uint64(v)
+	}
+	return id, nil
+}
+
+// Parse 同时接受十进制数字 ID 和带前缀的字符串形式
+func Parse(prefix, s string) (uint64, error) {
+	if strings.HasPrefix(s, prefix+"_") {
+		return Decode(prefix, s)
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidID
+	}
+	return id, nil
+}
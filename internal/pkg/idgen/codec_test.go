@@ -0,0 +1,92 @@
+package idgen
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	s, err := NewSnowflake(42, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snow, err := s.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []uint64{0, 1, 31, 32, 123456789, snow, math.MaxInt64, math.MaxUint64} {
+		enc := Encode("usr", id)
+		if len(enc) != len("usr_")+encodedLen || !strings.HasPrefix(enc, "usr_") {
+			t.Errorf("Encode(%d) = %q", id, enc)
+		}
+		// 正文不区分大小写
+		for _, in := range []string{enc, "usr_" + strings.ToUpper(enc[4:])} {
+			got, err := Decode("usr", in)
+			if err != nil || got != id {
+				t.Errorf("Decode(%q) = %d, %v; want %d", in, got, err, id)
+			}
+			if got, err := Parse("usr", in); err != nil || got != id {
+				t.Errorf("Parse(%q) = %d, %v; want %d", in, got, err, id)
+			}
+		}
+	}
+}
+
+func TestEncodeKeepsOrder(t *testing.T) {
+	ids := []uint64{0, 1, 1 << 22, 1<<22 + 1, 1 << 40, math.MaxInt64}
+	for i := 1; i < len(ids); i++ {
+		if a, b := Encode("usr", ids[i-1]), Encode("usr", ids[i]); a >= b {
+			t.Errorf("Encode(%d) = %q not before Encode(%d) = %q", ids[i-1], a, ids[i], b)
+		}
+	}
+}
+
+func TestDecodeCrockfordAliases(t *testing.T) {
+	// o/O 读作 0，i/I/l/L 读作 1
+	want, err := Decode("usr", "usr_0000000000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []string{"usr_ooooooooooooi", "usr_OOOOOOOOOOOOL", "usr_0000000000001"} {
+		if got, err := Decode("usr", in); err != nil || got != want {
+			t.Errorf("Decode(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+		err  bool
+	}{
+		{"12345", 12345, false},
+		{"18446744073709551615", math.MaxUint64, false},
+		{Encode("usr", 12345), 12345, false},
+		{"", 0, true},
+		{"-1", 0, true},
+		{"18446744073709551616", 0, true}, // 溢出
+		{"usr_", 0, true},
+		{"usr_000000000000", 0, true},   // 少一位
+		{"usr_00000000000000", 0, true}, // 多一位
+		{"usr_000000000000u", 0, true},  // u 不在字母表里
+		{"usr_g000000000000", 0, true},  // 首字符超过 4 位，溢出 64 位
+		{"ord_0000000000001", 0, true},  // 前缀不对
+		{Encode("USR", 1), 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse("usr", tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidID) {
+				t.Errorf("Parse(%q) = %d, %v; want ErrInvalidID", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
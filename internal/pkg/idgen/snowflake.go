@@ -0,0 +1,100 @@
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Generator：应用侧生成主键，写库前就能拿到 ID（outbox 消息、缓存 key 都能提前构造）
+type Generator interface {
+	NextID() (uint64, error)
+}
+
+// 布局（63 位，最高位恒为 0，兼容有符号 BIGINT）：
+// 41 位毫秒时间戳（自 Epoch 起，约 69 年）| 10 位节点 | 12 位序列
+const (
+	nodeBits = 10
+	seqBits  = 12
+
+	MaxNode = 1<<nodeBits - 1
+	maxSeq  = 1<<seqBits - 1
+)
+
+// Epoch：2024-01-01 UTC，改了会和已有 ID 冲突
+var Epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var ErrClockBackwards = errors.New("idgen: clock moved backwards")
+
+// Snowflake：同一节点内单调递增，跨节点按时间大致有序
+type Snowflake struct {
+	mu   sync.Mutex
+	node uint64
+
+	// 时钟回拨（NTP 校时等）在 maxBackward 以内就原地等到追上，超出直接报错，
+	// 宁可这次写入失败也不发重复 ID
+	maxBackward time.Duration
+
+	lastMs int64
+	seq    uint64
+
+	now func() time.Time // 测试里替换
+}
+
+func NewSnowflake(node int64, maxBackward time.Duration) (*Snowflake, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("idgen: node %d out of range [0, %d]", node, MaxNode)
+	}
+	return &Snowflake{
+		node:        uint64(node),
+		maxBackward: maxBackward,
+		lastMs:      -1,
+		now:         time.Now,
+	}, nil
+}
+
+func (s *Snowflake) NextID() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := s.nowMs()
+	if ms < s.lastMs {
+		back := time.Duration(s.lastMs-ms) * time.Millisecond
+		if back > s.maxBackward {
+			return 0, fmt.Errorf("%w by %s", ErrClockBackwards, back)
+		}
+		time.Sleep(back)
+		ms = s.waitAfter(s.lastMs - 1)
+	}
+
+	if ms == s.lastMs {
+		s.seq = (s.seq + 1) & maxSeq
+		if s.seq == 0 { // 本毫秒序列用完，等下一毫秒
+			ms = s.waitAfter(s.lastMs)
+		}
+	} else {
+		s.seq = 0
+	}
+	s.lastMs = ms
+
+	return uint64(ms)<<(nodeBits+seqBits) | s.node<<seqBits | s.seq, nil
+}
+
+func (s *Snowflake) nowMs() int64 {
+	return s.now().UnixMilli() - Epoch.UnixMilli()
+}
+
+func (s *Snowflake) waitAfter(ms int64) int64 {
+	now := s.nowMs()
+	for now <= ms {
+		time.Sleep(100 * time.Microsecond)
+		now = s.nowMs()
+	}
+	return now
+}
+
+// Time 从 ID 里取出生成时间（毫秒精度）
+func Time(id uint64) time.Time {
+	return Epoch.Add(time.Duration(id>>(nodeBits+seqBits)) * time.Millisecond)
+}
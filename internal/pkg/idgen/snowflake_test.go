@@ -0,0 +1,189 @@
+package idgen
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock：前 n 次调用返回 t，之后每次调用前进 1ms
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+	n  int
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.n > 0 {
+		c.n--
+	} else {
+		c.t = c.t.Add(time.Millisecond)
+	}
+	return c.t
+}
+
+func newTestSnowflake(t *testing.T, node int64, maxBackward time.Duration, clock *fakeClock) *Snowflake {
+	t.Helper()
+	s, err := NewSnowflake(node, maxBackward)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = clock.now
+	return s
+}
+
+func split(id uint64) (ms int64, node, seq uint64) {
+	return int64(id >> (nodeBits + seqBits)), id >> seqBits & MaxNode, id & maxSeq
+}
+
+func TestNewSnowflakeNodeRange(t *testing.T) {
+	tests := []struct {
+		node int64
+		ok   bool
+	}{
+		{-1, false},
+		{0, true},
+		{MaxNode, true},
+		{MaxNode + 1, false},
+	}
+	for _, tt := range tests {
+		_, err := NewSnowflake(tt.node, 0)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewSnowflake(%d) err = %v, want ok = %v", tt.node, err, tt.ok)
+		}
+	}
+}
+
+func TestSnowflakeLayout(t *testing.T) {
+	at := Epoch.Add(90 * 24 * time.Hour)
+	for _, node := range []int64{0, 1, 513, MaxNode} {
+		s := newTestSnowflake(t, node, 0, &fakeClock{t: at, n: 2})
+		first, err := s.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := s.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ms, n, seq := split(first); ms != at.Sub(Epoch).Milliseconds() || n != uint64(node) || seq != 0 {
+			t.Errorf("node %d: first id = (%d, %d, %d)", node, ms, n, seq)
+		}
+		if _, _, seq := split(second); seq != 1 || second <= first {
+			t.Errorf("node %d: second id %d (seq %d) after %d", node, second, seq, first)
+		}
+		if !Time(first).Equal(at) {
+			t.Errorf("node %d: Time = %v, want %v", node, Time(first), at)
+		}
+		if first>>63 != 0 {
+			t.Errorf("node %d: id %d sets the sign bit", node, first)
+		}
+	}
+}
+
+func TestSnowflakeSequenceRollover(t *testing.T) {
+	at := Epoch.Add(time.Hour)
+	// 同一毫秒里发满 4096 个，第 4097 个要等到下一毫秒、序列从 0 开始
+	s := newTestSnowflake(t, 7, 0, &fakeClock{t: at, n: maxSeq + 2})
+
+	var prev uint64
+	for i := 0; i <= maxSeq+1; i++ {
+		id, err := s.NextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && id <= prev {
+			t.Fatalf("id %d = %d not increasing after %d", i, id, prev)
+		}
+		prev = id
+
+		ms, _, seq := split(id)
+		wantMs, wantSeq := at.Sub(Epoch).Milliseconds(), uint64(i)
+		if i > maxSeq {
+			wantMs, wantSeq = wantMs+1, 0
+		}
+		if ms != wantMs || seq != wantSeq {
+			t.Fatalf("id %d = (ms %d, seq %d), want (%d, %d)", i, ms, seq, wantMs, wantSeq)
+		}
+	}
+}
+
+func TestSnowflakeClockBackwards(t *testing.T) {
+	at := Epoch.Add(time.Hour)
+	tests := []struct {
+		name        string
+		back        time.Duration
+		maxBackward time.Duration
+		wantErr     bool
+	}{
+		{"within tolerance waits", 2 * time.Millisecond, 5 * time.Millisecond, false},
+		{"at tolerance waits", 5 * time.Millisecond, 5 * time.Millisecond, false},
+		{"beyond tolerance fails", 6 * time.Millisecond, 5 * time.Millisecond, true},
+		{"no tolerance fails", time.Millisecond, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{t: at, n: 1}
+			s := newTestSnowflake(t, 1, tt.maxBackward, clock)
+			first, err := s.NextID()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clock.mu.Lock()
+			clock.t, clock.n = at.Add(-tt.back), 1
+			clock.mu.Unlock()
+
+			id, err := s.NextID()
+			if tt.wantErr {
+				if !errors.Is(err, ErrClockBackwards) {
+					t.Fatalf("err = %v, want ErrClockBackwards", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id <= first {
+				t.Errorf("id %d after clock went back, not above %d", id, first)
+			}
+		})
+	}
+}
+
+func TestSnowflakeConcurrentUnique(t *testing.T) {
+	s, err := NewSnowflake(3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const workers, each = 8, 2000
+	ids := make(chan uint64, workers*each)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range each {
+				id, err := s.NextID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[uint64]bool, workers*each)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate id %d", id)
+		}
+		seen[id] = true
+	}
+}
@@ -18,9 +18,79 @@ var (
 	OutboxFailedTotal   = expvar.NewInt("outbox_failed_total")
 	OutboxPolledTotal   = expvar.NewInt("outbox_polled_total")
 
+	// 提交后唤醒：server 发出的 nudge 数，worker 被唤醒（而不是等轮询到期）的次数
+	OutboxNudgesTotal  = expvar.NewInt("outbox_nudges_total")
+	OutboxWakeupsTotal = expvar.NewInt("outbox_wakeups_total")
+
+	// binlog 中继：是否正在订阅，回退到轮询的次数，最近一次提交事务距今的延迟
+	OutboxCDCActive         = expvar.NewInt("outbox_cdc_active")
+	OutboxCDCFallbacksTotal = expvar.NewInt("outbox_cdc_fallbacks_total")
+	OutboxCDCLagMs          = expvar.NewInt("outbox_cdc_lag_ms")
+
+	// 分区投递：每条 lane 的排队深度和队头消息的等待时长（从写入 outbox 算起）
+	OutboxLaneDepth    = expvar.NewMap("outbox_lane_depth")
+	OutboxLaneHOLAgeMs = expvar.NewMap("outbox_lane_hol_age_ms")
+
+	OutboxDeadTotal = expvar.NewInt("outbox_dead_total")
+	OutboxDeadRows  = expvar.NewInt("outbox_dead_rows") // 告警：> 0 说明有消息需要人工处理
+
+	OutboxPurgedTotal   = expvar.NewInt("outbox_purged_total")
+	OutboxArchivedTotal = expvar.NewInt("outbox_archived_total")
+	OutboxTableRows     = expvar.NewInt("outbox_table_rows")
+	OutboxTableBytes    = expvar.NewInt("outbox_table_bytes")
+
 	ConsumerProcessedTotal = expvar.NewInt("consumer_processed_total")
 	ConsumerFailedTotal    = expvar.NewInt("consumer_failed_total")
 	ConsumerDLQTotal       = expvar.NewInt("consumer_dlq_total")
+
+	// inbox：被去重挡下的重复消息（filtered 是 Redis 快速过滤挡下的部分），保留期清理的行数
+	// lease_waits：同一事件正在别处处理，退避等待的次数
+	InboxDuplicatesTotal = expvar.NewInt("inbox_duplicates_total")
+	InboxFilteredTotal   = expvar.NewInt("inbox_filtered_total")
+	InboxLeaseWaitsTotal = expvar.NewInt("inbox_lease_waits_total")
+	InboxPurgedTotal     = expvar.NewInt("inbox_purged_total")
+
+	// 事件 schema 校验失败：rejected 是写 outbox 时被拒绝的，invalid_consumed 是消费端收到的
+	// upcast：消费端收到旧版本、升级到最新版本后再处理的消息数
+	EventsRejectedTotal        = expvar.NewInt("events_rejected_total")
+	EventsInvalidConsumedTotal = expvar.NewInt("events_invalid_consumed_total")
+	EventsUpcastTotal          = expvar.NewInt("events_upcast_total")
+
+	UserCacheNegativeHitTotal  = expvar.NewInt("user_cache_negative_hit_total")
+	UserCacheEarlyRefreshTotal = expvar.NewInt("user_cache_early_refresh_total")
+	UserLoadSharedTotal        = expvar.NewInt("user_load_shared_total")
+
+	UserCacheL1HitTotal  = expvar.NewInt("user_cache_l1_hit_total")
+	UserCacheL1MissTotal = expvar.NewInt("user_cache_l1_miss_total")
+	UserCacheL2HitTotal  = expvar.NewInt("user_cache_l2_hit_total")
+	UserCacheL2MissTotal = expvar.NewInt("user_cache_l2_miss_total")
+
+	UserCacheDecodeErrorTotal = expvar.NewInt("user_cache_decode_error_total")
+
+	CacheSyncAppliedTotal = expvar.NewInt("cache_sync_applied_total")
+	CacheSyncStaleTotal   = expvar.NewInt("cache_sync_stale_total")
+	CacheSyncFailedTotal  = expvar.NewInt("cache_sync_failed_total")
+
+	DBPrimaryReadsTotal       = expvar.NewInt("db_primary_reads_total")
+	DBReplicaReadsTotal       = expvar.NewInt("db_replica_reads_total")
+	DBReplicasHealthy         = expvar.NewInt("db_replicas_healthy")
+	DBReplicaCheckErrorsTotal = expvar.NewInt("db_replica_check_errors_total")
+
+	DBTxRetriesTotal        = expvar.NewInt("db_tx_retries_total")
+	DBTxRetryExhaustedTotal = expvar.NewInt("db_tx_retry_exhausted_total")
+	TxHookPanicsTotal       = expvar.NewInt("tx_hook_panics_total")
+
+	// 按语句名统计（key 见 sqlmetrics.StatementName）
+	DBQueriesTotal     = expvar.NewMap("db_queries_total")
+	DBQueryErrorsTotal = expvar.NewMap("db_query_errors_total")
+	DBQueryDurationUs  = expvar.NewMap("db_query_duration_us_total")
+	DBSlowQueriesTotal = expvar.NewInt("db_slow_queries_total")
+
+	DBPoolOpen           = expvar.NewInt("db_pool_open")
+	DBPoolInUse          = expvar.NewInt("db_pool_in_use")
+	DBPoolIdle           = expvar.NewInt("db_pool_idle")
+	DBPoolWaitCount      = expvar.NewInt("db_pool_wait_count")
+	DBPoolWaitDurationMs = expvar.NewInt("db_pool_wait_duration_ms")
 )
 
 func ObserveHTTPLatency(d time.Duration) {
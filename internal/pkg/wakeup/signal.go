@@ -0,0 +1,24 @@
+package wakeup
+
+import "context"
+
+// Signal：进程内的唤醒信号，容量为 1，连续多次 Notify 在消费前合并成一次
+type Signal struct {
+	ch chan struct{}
+}
+
+func NewSignal() *Signal {
+	return &Signal{ch: make(chan struct{}, 1)}
+}
+
+// Notify 不阻塞：已有未消费的信号时直接丢弃
+func (s *Signal) Notify(context.Context) {
+	select {
+	case s.ch <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Signal) C() <-chan struct{} {
+	return s.ch
+}